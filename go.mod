@@ -3,6 +3,7 @@ module github.com/kndndrj/sway-scripts
 go 1.24
 
 require (
+	github.com/BurntSushi/toml v1.5.0
 	github.com/joshuarubin/go-sway v1.2.0
 	github.com/neurlang/wayland v0.2.2
 	github.com/stretchr/testify v1.9.0
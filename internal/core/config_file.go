@@ -0,0 +1,107 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// ConfigPath returns the location of a config file of the provided program:
+// $XDG_CONFIG_HOME/<program>/config
+func ConfigPath(program string) string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+
+	return filepath.Join(dir, program, "config")
+}
+
+// StateDir returns the directory for state files (logs, history) of the provided program:
+// $XDG_STATE_HOME/<program>
+func StateDir(program string) string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+
+	return filepath.Join(dir, program)
+}
+
+// DecodeTOML decodes TOML data into v and rejects unknown keys.
+// Errors are prefixed with "<path>:<line>:".
+func DecodeTOML(path string, data []byte, v any) error {
+	md, err := toml.NewDecoder(bytes.NewReader(data)).Decode(v)
+	if err != nil {
+		var perr toml.ParseError
+		if errors.As(err, &perr) {
+			if perr.LastKey != "" {
+				return fmt.Errorf("%s:%d: %s: %s", path, perr.Position.Line, perr.LastKey, perr.Message)
+			}
+			return fmt.Errorf("%s:%d: %s", path, perr.Position.Line, perr.Message)
+		}
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		key := undecoded[0]
+		return fmt.Errorf("%s:%d: unknown key: %q", path, keyLine(data, key), key.String())
+	}
+
+	return nil
+}
+
+// TableLine returns the line where the table with the provided key is defined,
+// either as a "[table]" header or as an inline table (0 if not found).
+func TableLine(data []byte, key ...string) int {
+	if len(key) == 0 {
+		return 0
+	}
+
+	parts := make([]string, len(key))
+	for i, k := range key {
+		name := regexp.QuoteMeta(k)
+		parts[i] = `(` + name + `|"` + name + `"|'` + name + `')`
+	}
+	re := regexp.MustCompile(`^\s*\[\s*` + strings.Join(parts, `\s*\.\s*`) + `\s*\]`)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		if re.MatchString(scanner.Text()) {
+			return line
+		}
+	}
+	return keyLine(data, key)
+}
+
+// keyLine returns the line where the key is first assigned (0 if not found).
+func keyLine(data []byte, key toml.Key) int {
+	if len(key) == 0 {
+		return 0
+	}
+	name := regexp.QuoteMeta(key[len(key)-1])
+	re := regexp.MustCompile(`^\s*(` + name + `|"` + name + `"|'` + name + `')\s*=`)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		if re.MatchString(scanner.Text()) {
+			return line
+		}
+	}
+	return 0
+}
@@ -4,6 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/joshuarubin/go-sway"
 )
@@ -20,44 +23,55 @@ func NewNodeNinja(cl sway.Client) *NodeNinja {
 	}
 }
 
-// getWorkspaceNode returns the node of the provided workspace.
-func (nn *NodeNinja) getWorkspaceNode(ctx context.Context, workspace *sway.Workspace) (*sway.Node, error) {
-	t, err := nn.client.GetTree(ctx)
+// FindFocusedWorkspace finds the currently focused workspace.
+func (nn *NodeNinja) FindFocusedWorkspace(ctx context.Context) (*sway.Workspace, error) {
+	workspaces, err := nn.client.GetWorkspaces(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("eh.client.GetTree: %w", err)
+		return nil, fmt.Errorf("eh.client.GetWorkspaces: %w", err)
 	}
 
-	node := t.TraverseNodes(func(n *sway.Node) bool {
-		if n.Type == sway.NodeWorkspace &&
-			n.Name == workspace.Name {
-			return true
+	for _, w := range workspaces {
+		if w.Focused {
+			return &w, nil
 		}
+	}
 
-		return false
-	})
+	// should never come to this
+	return nil, errors.New("no focused workspace")
+}
 
-	if node == nil {
-		return nil, fmt.Errorf("workspace with number: %d not found", workspace.Num)
+// ListWorkspaces returns all workspaces.
+func (nn *NodeNinja) ListWorkspaces(ctx context.Context) ([]sway.Workspace, error) {
+	workspaces, err := nn.client.GetWorkspaces(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("eh.client.GetWorkspaces: %w", err)
 	}
-
-	return node, nil
+	return workspaces, nil
 }
 
-// FindFocusedWorkspace finds the currently focused workspace.
-func (nn *NodeNinja) FindFocusedWorkspace(ctx context.Context) (*sway.Workspace, error) {
+// FindWorkspace finds a workspace by its name or number.
+func (nn *NodeNinja) FindWorkspace(ctx context.Context, nameOrNum string) (*sway.Workspace, error) {
 	workspaces, err := nn.client.GetWorkspaces(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("eh.client.GetWorkspaces: %w", err)
 	}
 
+	// exact name match has priority
 	for _, w := range workspaces {
-		if w.Focused {
+		if w.Name == nameOrNum {
 			return &w, nil
 		}
 	}
 
-	// should never come to this
-	return nil, errors.New("no focused workspace")
+	if num, err := strconv.Atoi(nameOrNum); err == nil {
+		for _, w := range workspaces {
+			if int(w.Num) == num {
+				return &w, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("workspace %q not found", nameOrNum)
 }
 
 // FindFocusedNode finds the currently focused node.
@@ -74,6 +88,51 @@ func (nn *NodeNinja) FindFocusedNode(ctx context.Context) (*sway.Node, error) {
 	return nil, errors.New("focused node not found")
 }
 
+// ScratchWorkspace is the name of the hidden workspace holding scratchpad windows.
+const ScratchWorkspace = "__i3_scratch"
+
+// ErrNodeNotFound is returned if the node is not in the tree.
+var ErrNodeNotFound = errors.New("node not found")
+
+// NodeLocation tells where in the tree a node lives.
+type NodeLocation struct {
+	Workspace string
+	Output    string
+}
+
+// InScratchpad reports if the node is hidden in the scratchpad.
+func (l *NodeLocation) InScratchpad() bool {
+	return l.Workspace == ScratchWorkspace
+}
+
+// FindNodeLocation finds the workspace and output of the node with the provided id.
+func (nn *NodeNinja) FindNodeLocation(ctx context.Context, id int64) (*NodeLocation, error) {
+	tree, err := nn.client.GetTree(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("eh.client.GetTree: %w", err)
+	}
+
+	for _, out := range tree.Nodes {
+		for _, ws := range out.Nodes {
+			if ws.Type != sway.NodeWorkspace {
+				continue
+			}
+
+			node := ws.TraverseNodes(func(n *sway.Node) bool {
+				return n.ID == id
+			})
+			if node != nil {
+				return &NodeLocation{
+					Workspace: ws.Name,
+					Output:    out.Name,
+				}, nil
+			}
+		}
+	}
+
+	return nil, ErrNodeNotFound
+}
+
 func filterConNodes(in []*sway.Node) []*sway.Node {
 	var out []*sway.Node
 	for _, n := range in {
@@ -84,64 +143,129 @@ func filterConNodes(in []*sway.Node) []*sway.Node {
 	return out
 }
 
-// WorkspaceGetTopLevelContainers returns top level containers in the provided workspace.
-func (nn *NodeNinja) WorkspaceGetTopLevelContainers(ctx context.Context, workspace *sway.Workspace) ([]*sway.Node, error) {
-	node, err := nn.getWorkspaceNode(ctx, workspace)
+// flattenCommands returns commands that remove containers with a single child under the node.
+func flattenCommands(rootNode *sway.Node) []string {
+	var cmds []string
+
+	// node without children
+	if len(rootNode.Nodes) == 1 &&
+		rootNode.Type == sway.NodeCon &&
+		rootNode.Nodes[0].Type == sway.NodeCon {
+		cmds = append(cmds, fmt.Sprintf("[con_id=%d] split none", rootNode.Nodes[0].ID))
+	}
+
+	for _, n := range rootNode.Nodes {
+		cmds = append(cmds, flattenCommands(n)...)
+	}
+
+	return cmds
+}
+
+// WorkspaceSnapshot is a workspace in a tree fetched at a single point in time.
+// A layout pass makes all decisions on it instead of fetching the tree repeatedly.
+type WorkspaceSnapshot struct {
+	Workspace *sway.Workspace
+	// Node is the workspace node.
+	Node *sway.Node
+	// Focused is the focused node if it's on the workspace (nil otherwise).
+	Focused *sway.Node
+}
+
+// SnapshotWorkspace fetches the tree once and returns the provided workspace in it.
+func (nn *NodeNinja) SnapshotWorkspace(ctx context.Context, workspace *sway.Workspace) (*WorkspaceSnapshot, error) {
+	tree, err := nn.client.GetTree(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("eh.getWorkspaceNode: %w", err)
+		return nil, fmt.Errorf("eh.client.GetTree: %w", err)
+	}
+
+	node := tree.TraverseNodes(func(n *sway.Node) bool {
+		return n.Type == sway.NodeWorkspace && n.Name == workspace.Name
+	})
+	if node == nil {
+		return nil, fmt.Errorf("workspace %q not found", workspace.Name)
 	}
 
-	return filterConNodes(node.Nodes), nil
+	return &WorkspaceSnapshot{
+		Workspace: workspace,
+		Node:      node,
+		Focused:   node.FocusedNode(),
+	}, nil
 }
 
-// WorkspaceFlattenChildren flattens children under the provided workspace.
-func (nn *NodeNinja) WorkspaceFlattenChildren(ctx context.Context, workspace *sway.Workspace) error {
-	var flatten func(rootNode *sway.Node) error
-	flatten = func(rootNode *sway.Node) error {
-		// node without children
-		if len(rootNode.Nodes) == 1 &&
-			rootNode.Type == sway.NodeCon &&
-			rootNode.Nodes[0].Type == sway.NodeCon {
-			_, err := nn.client.RunCommand(ctx, fmt.Sprintf("[con_id=%d] split none", rootNode.Nodes[0].ID))
-			if err != nil {
-				return fmt.Errorf("eh.client.RunCommand: %w", err)
-			}
-		}
+// FlattenCommands returns commands that flatten children of the workspace.
+func (s *WorkspaceSnapshot) FlattenCommands() []string {
+	return flattenCommands(s.Node)
+}
 
-		for _, n := range rootNode.Nodes {
-			err := flatten(n)
-			if err != nil {
-				return err
-			}
+// TopLevelContainers returns top level containers in the workspace.
+func (s *WorkspaceSnapshot) TopLevelContainers() []*sway.Node {
+	return filterConNodes(s.Node.Nodes)
+}
+
+// FlattenedTopLevelContainers returns top level containers as they are once the flatten
+// commands are applied: a container with a single child is replaced by the child.
+func (s *WorkspaceSnapshot) FlattenedTopLevelContainers() []*sway.Node {
+	top := filterConNodes(s.Node.Nodes)
+	for i, n := range top {
+		for len(n.Nodes) == 1 && n.Nodes[0].Type == sway.NodeCon {
+			n = n.Nodes[0]
 		}
+		top[i] = n
+	}
+	return top
+}
+
+// FocusedIsTopLevel reports if the focused node is one of the top level containers
+// once the flatten commands are applied.
+func (s *WorkspaceSnapshot) FocusedIsTopLevel() bool {
+	if s.Focused == nil {
+		return false
+	}
+
+	return slices.ContainsFunc(s.FlattenedTopLevelContainers(), func(n *sway.Node) bool {
+		return n.ID == s.Focused.ID
+	})
+}
 
+// RunCommands runs the commands in a single request. Empty commands are skipped.
+func (nn *NodeNinja) RunCommands(ctx context.Context, cmds []string) error {
+	var nonEmpty []string
+	for _, cmd := range cmds {
+		if cmd != "" {
+			nonEmpty = append(nonEmpty, cmd)
+		}
+	}
+	if len(nonEmpty) == 0 {
 		return nil
 	}
 
-	node, err := nn.getWorkspaceNode(ctx, workspace)
+	_, err := nn.client.RunCommand(ctx, strings.Join(nonEmpty, "; "))
 	if err != nil {
-		return fmt.Errorf("eh.getWorkspaceNode: %w", err)
+		return fmt.Errorf("eh.client.RunCommand: %w", err)
 	}
 
-	return flatten(node)
+	return nil
 }
 
 // ApplyOuterGaps applies gaps for current workspace.
 func (nn *NodeNinja) ApplyOuterGaps(ctx context.Context, horizontal, vertical int) error {
+	_, err := nn.client.RunCommand(ctx, OuterGapsCommand(horizontal, vertical))
+	if err != nil {
+		return fmt.Errorf("eh.client.RunCommand: %w", err)
+	}
+
+	return nil
+}
+
+// OuterGapsCommand returns the command that sets gaps of the current workspace.
+func OuterGapsCommand(horizontal, vertical int) string {
 	if horizontal < 0 {
 		horizontal = 0
 	}
 	if vertical < 0 {
 		vertical = 0
 	}
-	cmd := fmt.Sprintf("gaps horizontal current set %d; gaps vertical current set %d", horizontal, vertical)
-
-	_, err := nn.client.RunCommand(ctx, cmd)
-	if err != nil {
-		return fmt.Errorf("eh.client.RunCommand: %w", err)
-	}
-
-	return nil
+	return fmt.Sprintf("gaps horizontal current set %d; gaps vertical current set %d", horizontal, vertical)
 }
 
 // Direction represents orientation (horizontal/vertical)
@@ -169,19 +293,13 @@ func (nn *NodeNinja) NodeDetermineSplitDirection(node *sway.Node) Direction {
 	return DirectionHorizontal
 }
 
-// NodeApplySplitDirection applies split direction for a specific node.
-func (nn *NodeNinja) NodeApplySplitDirection(ctx context.Context, node *sway.Node, dir Direction) error {
+// SplitDirectionCommand returns the command that applies split direction for a specific node
+// (empty if the node already has it).
+func SplitDirectionCommand(node *sway.Node, dir Direction) string {
 	ly := dir.toLayout()
 	if node.Orientation == ly {
-		return nil
+		return ""
 	}
 
-	cmd := fmt.Sprintf("[con_id=%d] %s", node.ID, ly)
-
-	_, err := nn.client.RunCommand(ctx, cmd)
-	if err != nil {
-		return fmt.Errorf("eh.client.RunCommand: %w", err)
-	}
-
-	return nil
+	return fmt.Sprintf("[con_id=%d] %s", node.ID, ly)
 }
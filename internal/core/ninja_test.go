@@ -0,0 +1,165 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/joshuarubin/go-sway"
+	"github.com/stretchr/testify/require"
+)
+
+func con(id int64, children ...*sway.Node) *sway.Node {
+	return &sway.Node{ID: id, Type: sway.NodeCon, Nodes: children}
+}
+
+func workspace(children ...*sway.Node) *sway.Node {
+	return &sway.Node{ID: 100, Type: sway.NodeWorkspace, Name: "1", Nodes: children}
+}
+
+func TestFlattenCommands(t *testing.T) {
+	testCases := []struct {
+		comment  string
+		node     *sway.Node
+		expected []string
+	}{
+		{
+			comment:  "empty workspace",
+			node:     workspace(),
+			expected: nil,
+		},
+		{
+			comment:  "single window",
+			node:     workspace(con(1)),
+			expected: nil,
+		},
+		{
+			comment:  "split with two windows",
+			node:     workspace(con(1, con(2), con(3))),
+			expected: nil,
+		},
+		{
+			comment:  "container with a single window",
+			node:     workspace(con(1, con(2))),
+			expected: []string{"[con_id=2] split none"},
+		},
+		{
+			comment:  "nested containers with a single child",
+			node:     workspace(con(1, con(2, con(3)))),
+			expected: []string{"[con_id=2] split none", "[con_id=3] split none"},
+		},
+		{
+			comment:  "single child deeper in a split",
+			node:     workspace(con(1), con(2, con(3), con(4, con(5)))),
+			expected: []string{"[con_id=5] split none"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.comment, func(t *testing.T) {
+			require.Equal(t, tc.expected, flattenCommands(tc.node))
+		})
+	}
+}
+
+func TestWorkspaceSnapshot_FlattenedTopLevelContainers(t *testing.T) {
+	testCases := []struct {
+		comment  string
+		node     *sway.Node
+		expected []int64
+	}{
+		{
+			comment:  "empty workspace",
+			node:     workspace(),
+			expected: nil,
+		},
+		{
+			comment:  "windows side by side",
+			node:     workspace(con(1), con(2)),
+			expected: []int64{1, 2},
+		},
+		{
+			comment:  "container with a single window is replaced by it",
+			node:     workspace(con(1, con(2)), con(3)),
+			expected: []int64{2, 3},
+		},
+		{
+			comment:  "nested containers with a single child",
+			node:     workspace(con(1, con(2, con(3)))),
+			expected: []int64{3},
+		},
+		{
+			comment:  "split is kept",
+			node:     workspace(con(1, con(2), con(3))),
+			expected: []int64{1},
+		},
+		{
+			comment:  "only containers",
+			node:     workspace(con(1), &sway.Node{ID: 2, Type: sway.NodeFloatingCon}),
+			expected: []int64{1},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.comment, func(t *testing.T) {
+			snap := &WorkspaceSnapshot{Node: tc.node}
+
+			var ids []int64
+			for _, n := range snap.FlattenedTopLevelContainers() {
+				ids = append(ids, n.ID)
+			}
+			require.Equal(t, tc.expected, ids)
+		})
+	}
+}
+
+func TestWorkspaceSnapshot_FocusedIsTopLevel(t *testing.T) {
+	testCases := []struct {
+		comment  string
+		node     *sway.Node
+		focused  int64
+		expected bool
+	}{
+		{
+			comment:  "top level window",
+			node:     workspace(con(1), con(2)),
+			focused:  2,
+			expected: true,
+		},
+		{
+			comment:  "window in a container that is flattened",
+			node:     workspace(con(1, con(2)), con(3)),
+			focused:  2,
+			expected: true,
+		},
+		{
+			comment:  "window in a split",
+			node:     workspace(con(1, con(2), con(3))),
+			focused:  3,
+			expected: false,
+		},
+		{
+			comment:  "window in a split under a flattened container",
+			node:     workspace(con(1, con(2, con(3), con(4)))),
+			focused:  4,
+			expected: false,
+		},
+		{
+			comment:  "nothing focused",
+			node:     workspace(con(1)),
+			expected: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.comment, func(t *testing.T) {
+			snap := &WorkspaceSnapshot{Node: tc.node}
+			if tc.focused != 0 {
+				snap.Focused = tc.node.TraverseNodes(func(n *sway.Node) bool {
+					return n.ID == tc.focused
+				})
+				require.NotNil(t, snap.Focused)
+			}
+
+			require.Equal(t, tc.expected, snap.FocusedIsTopLevel())
+		})
+	}
+}
@@ -11,6 +11,9 @@ import (
 // Output represents physical and pixel dimensions of a monitor.
 type Output struct {
 	Name           string
+	Make           string
+	Model          string
+	Serial         string
 	Width          int
 	Height         int
 	PhysicalWidth  int
@@ -93,6 +96,9 @@ func (c *OutputCache) fetch(ctx context.Context) (map[string]*Output, error) {
 
 		lookup[o.Name] = &Output{
 			Name:           o.Name,
+			Make:           o.Make,
+			Model:          o.Model,
+			Serial:         o.Serial,
 			Width:          int(o.Rect.Width),
 			Height:         int(o.Rect.Height),
 			PhysicalWidth:  p.PhysicalWidth,
@@ -105,6 +111,12 @@ func (c *OutputCache) fetch(ctx context.Context) (map[string]*Output, error) {
 	return lookup, nil
 }
 
+// Identifier returns the output identifier in the same format as sway uses it
+// in the output configuration ("<make> <model> <serial>").
+func (o *Output) Identifier() string {
+	return o.Make + " " + o.Model + " " + o.Serial
+}
+
 func (c *OutputCache) Invalidate() {
 	c.isValid = false
 }
@@ -37,27 +37,52 @@ func ClearSocket(name string) error {
 	return nil
 }
 
-func Invoke(socketName string, msg any) error {
+// dial connects to the server, sends the message and reads the reply.
+// Returned decoder reads anything the server writes after the reply.
+func dial(socketName string, msg any) (net.Conn, *json.Decoder, *Reply, error) {
 	path, err := getSocketPath(socketName)
 	if err != nil {
-		return err
+		return nil, nil, nil, err
 	}
 
 	c, err := net.Dial("unix", path)
 	if err != nil {
-		return fmt.Errorf("net.Dial: %w", err)
+		return nil, nil, nil, fmt.Errorf("net.Dial: %w", err)
 	}
-	defer c.Close()
 
 	b, err := json.Marshal(msg)
 	if err != nil {
-		return fmt.Errorf("json.Marshal: %w", err)
+		c.Close()
+		return nil, nil, nil, fmt.Errorf("json.Marshal: %w", err)
 	}
 
 	_, err = c.Write(b)
 	if err != nil {
-		return fmt.Errorf("c.Write: %w", err)
+		c.Close()
+		return nil, nil, nil, fmt.Errorf("c.Write: %w", err)
 	}
 
-	return nil
+	decoder := json.NewDecoder(c)
+
+	var reply Reply
+	err = decoder.Decode(&reply)
+	if err != nil {
+		c.Close()
+		return nil, nil, nil, fmt.Errorf("decoder.Decode: %w", err)
+	}
+
+	return c, decoder, &reply, nil
+}
+
+// Invoke sends the message to the server listening on the socket and waits for its reply.
+// If resp is not nil, reply payload is decoded into it.
+// Errors that occured on the server side are returned as *RemoteError.
+func Invoke(socketName string, msg any, resp any) error {
+	c, _, reply, err := dial(socketName, msg)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	return reply.unwrap(resp)
 }
@@ -0,0 +1,81 @@
+package socket
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Status describes the outcome of handling a socket message.
+type Status string
+
+const (
+	StatusOK    Status = "ok"
+	StatusError Status = "error"
+)
+
+// Reply is written back to the caller on the same connection
+// after the message has been handled.
+type Reply struct {
+	Status  Status          `json:"status"`
+	Error   string          `json:"error,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// RemoteError is returned by Invoke when the server failed to handle the message.
+type RemoteError struct {
+	Message string
+}
+
+func (e *RemoteError) Error() string {
+	return e.Message
+}
+
+// newReply wraps the callback result into a reply envelope.
+func newReply(payload any, err error) *Reply {
+	if err != nil {
+		return &Reply{
+			Status: StatusError,
+			Error:  err.Error(),
+		}
+	}
+
+	if payload == nil {
+		return &Reply{Status: StatusOK}
+	}
+
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return &Reply{
+			Status: StatusError,
+			Error:  fmt.Sprintf("json.Marshal: %s", err),
+		}
+	}
+
+	return &Reply{
+		Status:  StatusOK,
+		Payload: raw,
+	}
+}
+
+// unwrap returns the error carried by the reply or decodes
+// the payload into resp (if provided).
+func (r *Reply) unwrap(resp any) error {
+	switch r.Status {
+	case StatusOK:
+	case StatusError:
+		return &RemoteError{Message: r.Error}
+	default:
+		return fmt.Errorf("unknown reply status: %q", r.Status)
+	}
+
+	if resp == nil || len(r.Payload) == 0 {
+		return nil
+	}
+
+	err := json.Unmarshal(r.Payload, resp)
+	if err != nil {
+		return fmt.Errorf("json.Unmarshal: %w", err)
+	}
+
+	return nil
+}
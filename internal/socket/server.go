@@ -3,31 +3,102 @@ package socket
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net"
 	"os"
+	"sync"
+	"time"
 )
 
+// ErrServerClosed is returned by Serve after a call to Shutdown or Close.
+var ErrServerClosed = errors.New("socket server closed")
+
+const (
+	defaultReadTimeout  = 5 * time.Second
+	defaultWriteTimeout = 5 * time.Second
+	defaultMaxInFlight  = 16
+)
+
+// Callback handles a single message. Returned payload (if any) and error
+// are sent back to the caller. If the payload is a Stream, the connection
+// is kept open after the reply.
+type Callback[MSG any] func(context.Context, *MSG) (any, error)
+
+type options struct {
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	maxInFlight  int
+}
+
+// Option configures the socket server.
+type Option func(*options)
+
+// WithReadTimeout sets the deadline for reading a message from a connection.
+func WithReadTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.readTimeout = d
+	}
+}
+
+// WithWriteTimeout sets the deadline for writing a single streamed message.
+// Callers that don't read fast enough are disconnected.
+func WithWriteTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.writeTimeout = d
+	}
+}
+
+// WithMaxInFlight limits the number of connections handled at the same time.
+// Open streams don't count towards the limit.
+func WithMaxInFlight(n int) Option {
+	return func(o *options) {
+		o.maxInFlight = n
+	}
+}
+
 // Server listens for requests on the socket and invokes the
 // provided callback on eash message.
+// Each connection is handled in its own goroutine.
 type Server[MSG any] struct {
 	log        *log.Logger
-	cb         func(context.Context, *MSG) error
+	cb         Callback[MSG]
 	socketPath string
+	opts       options
+
+	mu       sync.Mutex
+	listener net.Listener
+	closing  bool
+	done     chan struct{}
+	inFlight sync.WaitGroup
 }
 
-func NewServer[MSG any](logger *log.Logger, socketName string, cb func(context.Context, *MSG) error) (*Server[MSG], error) {
+func NewServer[MSG any](logger *log.Logger, socketName string, cb Callback[MSG], opts ...Option) (*Server[MSG], error) {
 	path, err := getSocketPath(socketName)
 	if err != nil {
 		return nil, err
 	}
 
+	o := options{
+		readTimeout:  defaultReadTimeout,
+		writeTimeout: defaultWriteTimeout,
+		maxInFlight:  defaultMaxInFlight,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.maxInFlight < 1 {
+		return nil, fmt.Errorf("invalid in-flight limit: %d", o.maxInFlight)
+	}
+
 	return &Server[MSG]{
 		log:        logger,
 		cb:         cb,
 		socketPath: path,
+		opts:       o,
+		done:       make(chan struct{}),
 	}, nil
 }
 
@@ -43,44 +114,183 @@ func (s *Server[MSG]) decodeJson(reader io.Reader) (*MSG, error) {
 	return ret, nil
 }
 
+// stop marks the server as closing and closes the listener.
+func (s *Server[MSG]) stop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closing {
+		return nil
+	}
+	s.closing = true
+	close(s.done)
+
+	if s.listener == nil {
+		return nil
+	}
+
+	err := s.listener.Close()
+	if err != nil && !errors.Is(err, net.ErrClosed) {
+		return fmt.Errorf("s.listener.Close: %w", err)
+	}
+	return nil
+}
+
+func (s *Server[MSG]) removeSocket() error {
+	err := os.Remove(s.socketPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("os.Remove: %w", err)
+	}
+	return nil
+}
+
+// Close immediately closes the listener and removes the socket file
+// without waiting for in-flight handlers.
 func (s *Server[MSG]) Close() error {
-	return os.Remove(s.socketPath)
+	err := s.stop()
+	if err != nil {
+		return err
+	}
+
+	return s.removeSocket()
+}
+
+// Shutdown closes the listener, waits for in-flight handlers to finish
+// and removes the socket file. If the context expires before all handlers
+// are done, the socket is removed anyway and the context error is returned.
+func (s *Server[MSG]) Shutdown(ctx context.Context) error {
+	err := s.stop()
+	if err != nil {
+		return err
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		s.inFlight.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	return errors.Join(err, s.removeSocket())
 }
 
+// Serve accepts connections until the context is cancelled or
+// the server is shut down.
 func (s *Server[MSG]) Serve(ctx context.Context) error {
 	l, err := net.Listen("unix", s.socketPath)
 	if err != nil {
 		return fmt.Errorf("net.Listen: %w", err)
 	}
 
+	s.mu.Lock()
+	if s.closing {
+		s.mu.Unlock()
+		l.Close()
+		return ErrServerClosed
+	}
+	s.listener = l
+	s.mu.Unlock()
+
+	// unblock Accept when context is cancelled
+	stopAfter := context.AfterFunc(ctx, func() {
+		l.Close()
+	})
+	defer stopAfter()
+
+	slots := make(chan struct{}, s.opts.maxInFlight)
+
 	for {
+		// wait for a free slot before accepting more connections
 		select {
+		case slots <- struct{}{}:
 		case <-ctx.Done():
 			return ctx.Err()
-		default:
+		case <-s.done:
+			return ErrServerClosed
 		}
 
 		fd, err := l.Accept()
 		if err != nil {
+			<-slots
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return ErrServerClosed
+			}
 			s.log.Printf("l.Accept: %s", err)
 			continue
 		}
 
-		message, err := s.decodeJson(fd)
+		s.mu.Lock()
+		if s.closing {
+			s.mu.Unlock()
+			fd.Close()
+			return ErrServerClosed
+		}
+		s.inFlight.Add(1)
+		s.mu.Unlock()
+
+		go func() {
+			defer s.inFlight.Done()
+
+			var once sync.Once
+			release := func() {
+				once.Do(func() { <-slots })
+			}
+			defer release()
+
+			s.handle(ctx, fd, release)
+		}()
+	}
+}
+
+// handle reads a message from the connection, passes it to the callback
+// and writes the reply back. Streams release their in-flight slot once the reply is written.
+func (s *Server[MSG]) handle(ctx context.Context, conn net.Conn, release func()) {
+	defer conn.Close()
+
+	var stream Stream
+
+	reply := func() *Reply {
+		err := conn.SetReadDeadline(time.Now().Add(s.opts.readTimeout))
 		if err != nil {
-			s.log.Print(err)
-			continue
+			return newReply(nil, fmt.Errorf("conn.SetReadDeadline: %w", err))
+		}
+
+		message, err := s.decodeJson(conn)
+		if err != nil {
+			return newReply(nil, fmt.Errorf("invalid message: %w", err))
 		}
 		if message == nil {
-			s.log.Printf("empty socket message")
-			continue
+			return newReply(nil, errors.New("empty socket message"))
 		}
 		s.log.Print("recieved message via socket")
 
-		err = s.cb(ctx, message)
+		payload, err := s.cb(ctx, message)
 		if err != nil {
 			s.log.Printf("s.cb: %s", err)
-			continue
 		}
+		if st, ok := payload.(Stream); ok && err == nil {
+			stream = st
+			payload = nil
+		}
+		return newReply(payload, err)
+	}()
+
+	err := json.NewEncoder(conn).Encode(reply)
+	if err != nil {
+		s.log.Printf("encoder.Encode: %s", err)
+		return
+	}
+
+	if stream != nil {
+		release()
+		s.stream(ctx, conn, stream)
 	}
 }
@@ -0,0 +1,215 @@
+package socket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testMessage struct {
+	Text string
+}
+
+func startTestServer(t *testing.T, cb Callback[testMessage], opts ...Option) *Server[testMessage] {
+	t.Helper()
+	r := require.New(t)
+
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+
+	srv, err := NewServer(log.New(io.Discard, "", 0), "test", cb, opts...)
+	r.NoError(err)
+
+	served := make(chan error, 1)
+	go func() {
+		served <- srv.Serve(context.Background())
+	}()
+
+	// wait for the listener to come up
+	r.Eventually(func() bool {
+		srv.mu.Lock()
+		defer srv.mu.Unlock()
+		return srv.listener != nil
+	}, time.Second, time.Millisecond)
+
+	t.Cleanup(func() {
+		_ = srv.Close()
+		r.ErrorIs(<-served, ErrServerClosed)
+	})
+
+	return srv
+}
+
+func TestServer_Reply(t *testing.T) {
+	r := require.New(t)
+
+	startTestServer(t, func(_ context.Context, msg *testMessage) (any, error) {
+		if msg.Text == "fail" {
+			return nil, errors.New("failed on purpose")
+		}
+		return &testMessage{Text: "echo: " + msg.Text}, nil
+	})
+
+	var resp testMessage
+	err := Invoke("test", &testMessage{Text: "hello"}, &resp)
+	r.NoError(err)
+	r.Equal("echo: hello", resp.Text)
+
+	err = Invoke("test", &testMessage{Text: "fail"}, &resp)
+	var remote *RemoteError
+	r.ErrorAs(err, &remote)
+	r.Equal("failed on purpose", remote.Message)
+}
+
+func TestServer_Concurrent(t *testing.T) {
+	r := require.New(t)
+
+	release := make(chan struct{})
+
+	startTestServer(t, func(_ context.Context, msg *testMessage) (any, error) {
+		if msg.Text == "slow" {
+			<-release
+		}
+		return nil, nil
+	}, WithMaxInFlight(2))
+
+	slow := make(chan error, 1)
+	go func() {
+		slow <- Invoke("test", &testMessage{Text: "slow"}, nil)
+	}()
+
+	// fast caller is not blocked by the slow one
+	r.NoError(Invoke("test", &testMessage{Text: "fast"}, nil))
+
+	close(release)
+	r.NoError(<-slow)
+}
+
+func TestServer_ShutdownDrains(t *testing.T) {
+	r := require.New(t)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	srv := startTestServer(t, func(context.Context, *testMessage) (any, error) {
+		close(started)
+		<-release
+		return nil, nil
+	})
+
+	call := make(chan error, 1)
+	go func() {
+		call <- Invoke("test", &testMessage{}, nil)
+	}()
+	<-started
+
+	// handler is still running - shutdown times out
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	r.ErrorIs(srv.Shutdown(ctx), context.DeadlineExceeded)
+
+	// in-flight handler still completes its reply
+	close(release)
+	r.NoError(<-call)
+	r.NoError(srv.Shutdown(context.Background()))
+
+	// new connections are refused
+	r.Error(Invoke("test", &testMessage{}, nil))
+}
+
+func TestServer_Stream(t *testing.T) {
+	r := require.New(t)
+
+	stopped := make(chan struct{})
+
+	srv := startTestServer(t, func(_ context.Context, msg *testMessage) (any, error) {
+		if msg.Text == "fail" {
+			return nil, errors.New("failed on purpose")
+		}
+
+		return Stream(func(ctx context.Context, send func(any) error) error {
+			defer func() {
+				if msg.Text == "forever" {
+					close(stopped)
+				}
+			}()
+
+			for i := range 3 {
+				err := send(&testMessage{Text: fmt.Sprintf("%s %d", msg.Text, i)})
+				if err != nil {
+					return err
+				}
+			}
+			if msg.Text == "broken" {
+				return errors.New("dropped on purpose")
+			}
+			if msg.Text != "finite" {
+				<-ctx.Done()
+			}
+			return nil
+		}), nil
+	}, WithMaxInFlight(1))
+
+	collect := func(text string, limit int) ([]string, error) {
+		var got []string
+		err := InvokeStream("test", &testMessage{Text: text}, func(raw json.RawMessage) error {
+			var msg testMessage
+			r.NoError(json.Unmarshal(raw, &msg))
+			got = append(got, msg.Text)
+			if len(got) == limit {
+				return errStop
+			}
+			return nil
+		})
+		return got, err
+	}
+
+	// stream ends on the server side
+	got, err := collect("finite", 0)
+	r.NoError(err)
+	r.Equal([]string{"finite 0", "finite 1", "finite 2"}, got)
+
+	// errors are returned before streaming
+	_, err = collect("fail", 0)
+	var remote *RemoteError
+	r.ErrorAs(err, &remote)
+
+	// stream that ends with an error is not a clean end
+	got, err = collect("broken", 0)
+	r.ErrorAs(err, &remote)
+	r.Equal("dropped on purpose", remote.Message)
+	r.Equal([]string{"broken 0", "broken 1", "broken 2"}, got)
+
+	// caller hangs up
+	got, err = collect("forever", 2)
+	r.ErrorIs(err, errStop)
+	r.Equal([]string{"forever 0", "forever 1"}, got)
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		r.Fail("stream not stopped after the caller hung up")
+	}
+
+	// open streams don't block other callers
+	open := make(chan error, 1)
+	go func() {
+		_, err := collect("open", -1)
+		open <- err
+	}()
+	r.Eventually(func() bool {
+		_, err := collect("finite", 0)
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+
+	// shutdown closes open streams
+	r.NoError(srv.Shutdown(context.Background()))
+	r.NoError(<-open)
+}
+
+var errStop = errors.New("stop")
@@ -0,0 +1,107 @@
+package socket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// Stream can be returned as a payload from the callback to keep the connection open
+// after the reply. Messages passed to send are written to the caller as
+// newline-delimited replies. The context is cancelled when the caller hangs up or the
+// server is shut down. The connection is closed once the function returns - if it
+// returns an error, the error is written to the caller as the final reply.
+type Stream func(ctx context.Context, send func(msg any) error) error
+
+// stream pushes messages to the connection until the stream ends.
+func (s *Server[MSG]) stream(ctx context.Context, conn net.Conn, stream Stream) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// caller is not expected to send anything else, so any read result means it's gone
+	go func() {
+		defer cancel()
+
+		err := conn.SetReadDeadline(time.Time{})
+		if err != nil {
+			return
+		}
+		_, _ = conn.Read(make([]byte, 1))
+	}()
+
+	// stop streaming on shutdown
+	go func() {
+		select {
+		case <-s.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	encoder := json.NewEncoder(conn)
+	write := func(r *Reply) error {
+		err := conn.SetWriteDeadline(time.Now().Add(s.opts.writeTimeout))
+		if err != nil {
+			return fmt.Errorf("conn.SetWriteDeadline: %w", err)
+		}
+		return encoder.Encode(r)
+	}
+	send := func(msg any) error {
+		raw, err := json.Marshal(msg)
+		if err != nil {
+			return fmt.Errorf("json.Marshal: %w", err)
+		}
+		return write(&Reply{Status: StatusOK, Payload: raw})
+	}
+
+	err := stream(ctx, send)
+	if err != nil {
+		s.log.Printf("stream: %s", err)
+
+		// caller might be gone already
+		_ = write(newReply(nil, err))
+	}
+}
+
+// InvokeStream sends the message to the server listening on the socket and calls
+// handle on each message streamed back after the reply.
+// It returns when the server closes the stream or handle returns an error.
+// Stream that ended with an error on the server side returns a *RemoteError.
+func InvokeStream(socketName string, msg any, handle func(json.RawMessage) error) error {
+	c, decoder, reply, err := dial(socketName, msg)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	err = reply.unwrap(nil)
+	if err != nil {
+		return err
+	}
+
+	for {
+		var frame Reply
+		err := decoder.Decode(&frame)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("decoder.Decode: %w", err)
+		}
+
+		var raw json.RawMessage
+		err = frame.unwrap(&raw)
+		if err != nil {
+			return err
+		}
+
+		err = handle(raw)
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/joshuarubin/go-sway"
+
+	"github.com/kndndrj/sway-scripts/internal/socket"
+	"github.com/kndndrj/sway-scripts/sway-reflex/reflex"
+)
+
+const socketName = "sway_reflex"
+
+// ctlMessage is passed throught the unix socket.
+type ctlMessage struct {
+	Command   reflex.Command
+	Workspace string
+
+	Dimension reflex.Dimension
+	Amount    int
+
+	WindowWidth  int
+	WindowHeight int
+}
+
+// ctlStatus is a reply to every control message.
+type ctlStatus struct {
+	Workspace    string
+	Enabled      bool
+	WindowWidth  int
+	WindowHeight int
+}
+
+// resolveWorkspace returns the workspace with the provided name or number,
+// or the focused one if name is empty.
+func (eh *eventHandler) resolveWorkspace(ctx context.Context, name string) (*sway.Workspace, error) {
+	if name == "" {
+		return eh.ninja.FindFocusedWorkspace(ctx)
+	}
+	return eh.ninja.FindWorkspace(ctx, name)
+}
+
+// refresh applies the current state to the focused workspace.
+func (eh *eventHandler) refresh(ctx context.Context, workspace *sway.Workspace) error {
+	cfg, enabled, err := eh.profile(ctx, workspace)
+	if err != nil {
+		return fmt.Errorf("eh.profile: %w", err)
+	}
+
+	if !enabled {
+		err := eh.ninja.ApplyOuterGaps(ctx, cfg.DefaultGapHorizontal, cfg.DefaultGapVertical)
+		if err != nil {
+			return fmt.Errorf("eh.ninja.ApplyOuterGaps: %w", err)
+		}
+		return nil
+	}
+
+	err = eh.autogap(ctx, workspace)
+	if err != nil {
+		return fmt.Errorf("eh.autogap: %w", err)
+	}
+	return nil
+}
+
+// reload replaces the config and reapplies the layout.
+// Adjustments made over the control socket are kept.
+func (eh *eventHandler) reload(ctx context.Context, cfg *reflex.Config) error {
+	eh.mu.Lock()
+	defer eh.mu.Unlock()
+
+	eh.cfg = cfg
+
+	workspaces, err := eh.ninja.ListWorkspaces(ctx)
+	if err != nil {
+		return fmt.Errorf("eh.ninja.ListWorkspaces: %w", err)
+	}
+
+	for _, w := range workspaces {
+		if !w.Focused {
+			eh.pending[w.Name] = struct{}{}
+			continue
+		}
+
+		err := eh.refresh(ctx, &w)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Control handles messages recieved over the control socket.
+func (eh *eventHandler) Control(ctx context.Context, msg *ctlMessage) (any, error) {
+	eh.mu.Lock()
+	defer eh.mu.Unlock()
+
+	workspace, err := eh.resolveWorkspace(ctx, msg.Workspace)
+	if err != nil {
+		return nil, err
+	}
+	name := workspace.Name
+
+	status := func() (*ctlStatus, error) {
+		cfg, enabled, err := eh.profile(ctx, workspace)
+		if err != nil {
+			return nil, fmt.Errorf("eh.profile: %w", err)
+		}
+		return &ctlStatus{
+			Workspace:    workspace.Name,
+			Enabled:      enabled,
+			WindowWidth:  cfg.PhysicalWindowWidth,
+			WindowHeight: cfg.PhysicalWindowHeight,
+		}, nil
+	}
+
+	current, err := status()
+	if err != nil {
+		return nil, err
+	}
+
+	switch msg.Command {
+	case reflex.CommandStatus:
+		return current, nil
+	case reflex.CommandEnable:
+		eh.enabled[name] = true
+	case reflex.CommandDisable:
+		eh.enabled[name] = false
+	case reflex.CommandToggle:
+		eh.enabled[name] = !current.Enabled
+	case reflex.CommandGrow, reflex.CommandShrink, reflex.CommandSize, reflex.CommandResetSize:
+		size, err := reflex.ResizeWindow(
+			reflex.WindowSize{Width: current.WindowWidth, Height: current.WindowHeight},
+			msg.Command, msg.Dimension, msg.Amount,
+			reflex.WindowSize{Width: msg.WindowWidth, Height: msg.WindowHeight},
+		)
+		if err != nil {
+			return nil, err
+		}
+		if size == nil {
+			delete(eh.windowSizes, name)
+		} else {
+			eh.windowSizes[name] = *size
+		}
+	default:
+		return nil, fmt.Errorf("unknown command: %q", msg.Command)
+	}
+
+	// gaps can only be applied to the focused workspace,
+	// others are refreshed once they get focus.
+	if workspace.Focused {
+		err := eh.refresh(ctx, workspace)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		eh.pending[name] = struct{}{}
+	}
+
+	return status()
+}
+
+// mainCtl is a main function for ctl mode.
+func mainCtl() error {
+	cfg, err := reflex.ParseCtlArgs(os.Args[2:])
+	if err != nil {
+		return err
+	}
+
+	var status ctlStatus
+	err = socket.Invoke(socketName, &ctlMessage{
+		Command:      cfg.Command,
+		Workspace:    cfg.Workspace,
+		Dimension:    cfg.Dimension,
+		Amount:       cfg.Amount,
+		WindowWidth:  cfg.WindowWidth,
+		WindowHeight: cfg.WindowHeight,
+	}, &status)
+	if err != nil {
+		var remote *socket.RemoteError
+		if errors.As(err, &remote) {
+			return remote
+		}
+		return fmt.Errorf("socket.Invoke: %w", err)
+	}
+
+	switch cfg.Command {
+	case reflex.CommandStatus:
+		if status.Enabled {
+			fmt.Println("enabled")
+		} else {
+			fmt.Println("disabled")
+		}
+	case reflex.CommandGrow, reflex.CommandShrink, reflex.CommandSize, reflex.CommandResetSize:
+		fmt.Printf("%dx%d\n", status.WindowWidth, status.WindowHeight)
+	}
+
+	return nil
+}
@@ -6,12 +6,15 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"strings"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/joshuarubin/go-sway"
 
 	"github.com/kndndrj/sway-scripts/internal/core"
+	"github.com/kndndrj/sway-scripts/internal/socket"
 	"github.com/kndndrj/sway-scripts/sway-reflex/reflex"
 )
 
@@ -24,137 +27,215 @@ type eventHandler struct {
 	ninja       *core.NodeNinja
 
 	cfg *reflex.Config
+	// state adjusted at runtime per workspace name
+	enabled     map[string]bool
+	windowSizes map[string]reflex.WindowSize
+	// workspaces that changed state while not focused
+	pending map[string]struct{}
+	// name of the focused workspace, tracked from workspace events (empty if not known)
+	focused string
+
+	// coalesces window events into layout passes
+	scheduler *reflex.Scheduler
+
+	// guards state shared between sway events and control messages.
+	mu sync.Mutex
+}
+
+// profile returns the effective config of the workspace and reports if reflex is enabled on it.
+// Adjustments made over the control socket take precedence over the config.
+func (eh *eventHandler) profile(ctx context.Context, workspace *sway.Workspace) (*reflex.Config, bool, error) {
+	out, err := eh.outputCache.Get(ctx, workspace.Output)
+	if err != nil {
+		return nil, false, fmt.Errorf("eh.outputCache.Get: %w", err)
+	}
+
+	cfg, enabled := eh.cfg.Resolve(out, workspace.Name, int(workspace.Num))
+	if size, ok := eh.windowSizes[workspace.Name]; ok {
+		cfg.PhysicalWindowWidth = size.Width
+		cfg.PhysicalWindowHeight = size.Height
+	}
+	if e, ok := eh.enabled[workspace.Name]; ok {
+		enabled = e
+	}
+
+	return cfg, enabled, nil
 }
 
 // getScreen retrieves or initializes and then returns a screen.
-func (eh *eventHandler) getScreen(ctx context.Context, outputName string) (*reflex.Screen, error) {
-	out, err := eh.outputCache.Get(ctx, outputName)
+func (eh *eventHandler) getScreen(ctx context.Context, workspace *sway.Workspace) (*reflex.Screen, error) {
+	out, err := eh.outputCache.Get(ctx, workspace.Output)
 	if err != nil {
 		return nil, fmt.Errorf("eh.outputCache.Get: %w", err)
 	}
 
-	return reflex.NewScreen(out, eh.cfg), nil
+	cfg, _, err := eh.profile(ctx, workspace)
+	if err != nil {
+		return nil, err
+	}
+
+	return reflex.NewScreen(out, cfg), nil
 }
 
+// autogap applies gaps and split directions of top level containers to the workspace.
 func (eh *eventHandler) autogap(ctx context.Context, workspace *sway.Workspace) error {
-	scr, err := eh.getScreen(ctx, workspace.Output)
+	snap, err := eh.ninja.SnapshotWorkspace(ctx, workspace)
+	if err != nil {
+		return fmt.Errorf("eh.ninja.SnapshotWorkspace: %w", err)
+	}
+
+	cmds, err := eh.autogapCommands(ctx, workspace, snap.TopLevelContainers())
+	if err != nil {
+		return err
+	}
+
+	err = eh.ninja.RunCommands(ctx, cmds)
 	if err != nil {
-		return fmt.Errorf("eh.getScreen: %w", err)
+		return fmt.Errorf("eh.ninja.RunCommands: %w", err)
 	}
+	return nil
+}
 
-	// get top level containers
-	topLevelContainers, err := eh.ninja.WorkspaceGetTopLevelContainers(ctx, workspace)
+// autogapCommands returns commands that apply gaps and split directions for the top level containers.
+func (eh *eventHandler) autogapCommands(ctx context.Context, workspace *sway.Workspace, topLevelContainers []*sway.Node) ([]string, error) {
+	scr, err := eh.getScreen(ctx, workspace)
 	if err != nil {
-		return fmt.Errorf("eh.ninja.WorkspaceGetTopLevelContainers: %w", err)
+		return nil, fmt.Errorf("eh.getScreen: %w", err)
 	}
 
 	// calculate dimensions of the enclosing container
 	cwidth, cheight := scr.CalculateContainerDimensions(len(topLevelContainers))
 
-	// calculate and apply gaps
+	// calculate gaps
 	hgaps, vgaps := scr.CalculateOuterGaps(cwidth, cheight)
-	err = eh.ninja.ApplyOuterGaps(ctx, hgaps, vgaps)
-	if err != nil {
-		return fmt.Errorf("eh.ninja.ApplyOuterGaps: %w", err)
-	}
+	cmds := []string{core.OuterGapsCommand(hgaps, vgaps)}
 
 	// when there is only top level container, we can set the general direction that holds
 	// true for the screen.
 	if len(topLevelContainers) == 1 {
-		err := eh.ninja.NodeApplySplitDirection(ctx, topLevelContainers[0], scr.Direction())
-		if err != nil {
-			return fmt.Errorf("eh.NodeApplySplitDirection: %w", err)
-		}
+		cmds = append(cmds, core.SplitDirectionCommand(topLevelContainers[0], scr.Direction()))
 	}
 
 	if scr.IsFilled(cwidth, cheight) {
 		for _, c := range topLevelContainers {
 			dir := eh.ninja.NodeDetermineSplitDirection(c)
-			err := eh.ninja.NodeApplySplitDirection(ctx, c, dir)
-			if err != nil {
-				return fmt.Errorf("eh.ninja.NodeApplySplitDirection: %w", err)
-			}
+			cmds = append(cmds, core.SplitDirectionCommand(c, dir))
 		}
 	}
 
-	return nil
+	return cmds, nil
 }
 
-func nodeExistsInSet(set []*sway.Node, n *sway.Node) bool {
-	for _, ex := range set {
-		if ex.ID == n.ID {
-			return true
+// layoutDelay is how long window events are collected before a layout pass.
+const layoutDelay = 50 * time.Millisecond
+
+// Window handler gets called on window events.
+// Events are only collected here, the layout pass runs once a burst of them is over.
+func (eh *eventHandler) Window(ctx context.Context, e sway.WindowEvent) {
+	// these can't change the layout
+	switch e.Change {
+	case sway.WindowTitle, sway.WindowMark, sway.WindowUrgent:
+		return
+	}
+
+	eh.mu.Lock()
+	defer eh.mu.Unlock()
+
+	if eh.focused == "" {
+		workspace, err := eh.ninja.FindFocusedWorkspace(ctx)
+		if err != nil {
+			eh.log.Printf("eh.ninja.FindFocusedWorkspace: %s", err)
+			return
 		}
+		eh.focused = workspace.Name
 	}
-	return false
+
+	eh.scheduler.Schedule(eh.focused, e.Change == sway.WindowClose)
 }
 
-// Window handler gets called on window events.
-func (eh *eventHandler) Window(ctx context.Context, e sway.WindowEvent) {
-	// IMPORTANT: need to search on instead of using the window from event.
-	// Events might be queued and out of sync.
-	focused, err := eh.ninja.FindFocusedNode(ctx)
+// layout runs a single layout pass on the workspace with the provided name.
+// closed reports that a window was closed since the last pass.
+func (eh *eventHandler) layout(ctx context.Context, name string, closed bool) {
+	eh.mu.Lock()
+	defer eh.mu.Unlock()
+
+	workspace, err := eh.ninja.FindWorkspace(ctx, name)
 	if err != nil {
-		eh.log.Printf("eh.ninja.FindFocusedNode: %s", err)
+		// workspace is gone
 		return
 	}
 
-	if focused.Type != sway.NodeCon {
+	// commands apply to the focused workspace, others are laid out once focused
+	if !workspace.Focused {
+		eh.pending[workspace.Name] = struct{}{}
 		return
 	}
 
-	workspace, err := eh.ninja.FindFocusedWorkspace(ctx)
+	// check if workspace is disabled
+	_, enabled, err := eh.profile(ctx, workspace)
 	if err != nil {
-		eh.log.Printf("eh.ninja.FindFocusedWorkspace: %s", err)
+		eh.log.Printf("eh.profile: %s", err)
 		return
 	}
-
-	// check if workspace is disabled
-	if _, ok := eh.cfg.DisabledWorkspaces[int(workspace.Num)]; ok {
+	if !enabled {
 		return
 	}
 
-	err = eh.ninja.WorkspaceFlattenChildren(ctx, workspace)
+	// IMPORTANT: need to search on instead of using the window from event.
+	// Events might be queued and out of sync.
+	snap, err := eh.ninja.SnapshotWorkspace(ctx, workspace)
 	if err != nil {
-		eh.log.Printf("eh.ninja.WorkspaceFlattenChildren: %s", err)
+		eh.log.Printf("eh.ninja.SnapshotWorkspace: %s", err)
 		return
 	}
 
-	topLevelContainers, err := eh.ninja.WorkspaceGetTopLevelContainers(ctx, workspace)
-	if err != nil {
-		eh.log.Printf("eh.ninja.WorkspaceGetTopLevelContainers: %s", err)
+	focused := snap.Focused
+	if focused == nil || focused.Type != sway.NodeCon {
 		return
 	}
 
+	// decisions are made on the tree as it is after flattening
+	cmds := snap.FlattenCommands()
+
 	// run autogaps for toplevel containers and autotiling for
 	// other nested windows.
-	if nodeExistsInSet(topLevelContainers, focused) || e.Change == sway.WindowClose {
-		err := eh.autogap(ctx, workspace)
+	if snap.FocusedIsTopLevel() || closed {
+		gapCmds, err := eh.autogapCommands(ctx, workspace, snap.FlattenedTopLevelContainers())
 		if err != nil {
-			eh.log.Printf("eh.autogap: %s", err)
+			eh.log.Printf("eh.autogapCommands: %s", err)
 			return
 		}
+		cmds = append(cmds, gapCmds...)
 	} else {
 		dir := eh.ninja.NodeDetermineSplitDirection(focused)
-		err = eh.ninja.NodeApplySplitDirection(ctx, focused, dir)
-		if err != nil {
-			eh.log.Printf("eh.ninja.NodeApplySplitDirection: %s", err)
-			return
-		}
+		cmds = append(cmds, core.SplitDirectionCommand(focused, dir))
+	}
+
+	err = eh.ninja.RunCommands(ctx, cmds)
+	if err != nil {
+		eh.log.Printf("eh.ninja.RunCommands: %s", err)
+		return
 	}
 }
 
 // Workspace handler gets called on workspace events.
 func (eh *eventHandler) Workspace(ctx context.Context, e sway.WorkspaceEvent) {
 	eh.outputCache.Invalidate()
-}
 
-func (eh *eventHandler) Binding(ctx context.Context, e sway.BindingEvent) {
-	// disable workspaces on certain binding events
-	cmd := e.Binding.Command
-	if !strings.Contains(cmd, "reflex:") {
+	eh.mu.Lock()
+	defer eh.mu.Unlock()
+
+	if e.Change != sway.WorkspaceFocus {
+		// focused workspace might have been renamed or moved - look it up again
+		if e.Change != sway.WorkspaceUrgent {
+			eh.focused = ""
+		}
 		return
 	}
+	eh.focused = ""
+	if e.Current != nil {
+		eh.focused = e.Current.Name
+	}
 
 	workspace, err := eh.ninja.FindFocusedWorkspace(ctx)
 	if err != nil {
@@ -162,39 +243,24 @@ func (eh *eventHandler) Binding(ctx context.Context, e sway.BindingEvent) {
 		return
 	}
 
-	enable := func() {
-		delete(eh.cfg.DisabledWorkspaces, int(workspace.Num))
-		err := eh.autogap(ctx, workspace)
-		if err != nil {
-			eh.log.Printf("eh.autogap: %s", err)
-			return
-		}
-	}
-
-	disable := func() {
-		eh.cfg.DisabledWorkspaces[int(workspace.Num)] = struct{}{}
-
-		err := eh.ninja.ApplyOuterGaps(ctx, eh.cfg.DefaultGapHorizontal, eh.cfg.DefaultGapVertical)
-		if err != nil {
-			eh.log.Printf("eh.ninja.ApplyOuterGaps: %s", err)
-		}
+	if _, ok := eh.pending[workspace.Name]; !ok {
+		return
 	}
+	delete(eh.pending, workspace.Name)
 
-	switch {
-	case strings.Contains(cmd, "disable_current"):
-		disable()
-	case strings.Contains(cmd, "enable_current"):
-		enable()
-	case strings.Contains(cmd, "toggle_current"):
-		if _, ok := eh.cfg.DisabledWorkspaces[int(workspace.Num)]; ok {
-			enable()
-		} else {
-			disable()
-		}
+	err = eh.refresh(ctx, workspace)
+	if err != nil {
+		eh.log.Printf("eh.refresh: %s", err)
 	}
 }
 
-func main() {
+const (
+	shutdownTimeout    = 5 * time.Second
+	configPollInterval = 2 * time.Second
+)
+
+// mainDaemon is a main function for daemon mode.
+func mainDaemon() error {
 	logger := log.New(os.Stdout, "reflex: ", log.LstdFlags)
 
 	// check pidfile
@@ -202,36 +268,131 @@ func main() {
 	if err != nil {
 		if errors.Is(err, core.ErrProcessAlreadyRunning) {
 			logger.Print("server already running")
-			return
+			return nil
 		}
-		logger.Fatalf("LockPidFile: %s", err)
+		return fmt.Errorf("core.LockPidFile: %w", err)
 	}
 
 	ctx := context.Background()
 
 	client, err := sway.New(ctx)
 	if err != nil {
-		logger.Fatalf("sway.New: %s", err)
+		return fmt.Errorf("sway.New: %w", err)
+	}
+
+	loader, err := reflex.ParseConfigFlags()
+	if err != nil {
+		return fmt.Errorf("reflex.ParseConfigFlags: %w", err)
+	}
+
+	cfg, err := loader.Load()
+	if err != nil {
+		return fmt.Errorf("loader.Load: %w", err)
 	}
 
-	cfg, err := reflex.ParseConfig()
+	// clear the socket file if it exists
+	err = socket.ClearSocket(socketName)
 	if err != nil {
-		logger.Fatalf("parseConfig: %s", err)
+		return fmt.Errorf("socket.ClearSocket: %w", err)
 	}
 
 	eh := &eventHandler{
 		log:         logger,
 		cfg:         cfg,
+		enabled:     make(map[string]bool),
+		windowSizes: make(map[string]reflex.WindowSize),
+		pending:     make(map[string]struct{}),
 		outputCache: core.NewOutputCache(client),
 		ninja:       core.NewNodeNinja(client),
 	}
+	eh.scheduler = reflex.NewScheduler(layoutDelay, func(workspace string, closed bool) {
+		eh.layout(ctx, workspace, closed)
+	})
+
+	// socket server for control messages
+	sock, err := socket.NewServer(logger, socketName, eh.Control)
+	if err != nil {
+		return fmt.Errorf("socket.NewServer: %w", err)
+	}
+
+	errc := make(chan error, 1)
+
+	go func() {
+		err := sock.Serve(ctx)
+		if err != nil && !errors.Is(err, socket.ErrServerClosed) {
+			errc <- fmt.Errorf("sock.Serve: %w", err)
+		}
+	}()
 
 	// start the event loop
-	for {
-		err = sway.Subscribe(ctx, eh, sway.EventTypeWindow, sway.EventTypeWorkspace, sway.EventTypeBinding)
+	go func() {
+		for {
+			err := sway.Subscribe(ctx, eh, sway.EventTypeWindow, sway.EventTypeWorkspace)
+			if err != nil {
+				logger.Printf("sway.Subscribe error: %s", err)
+			}
+			time.Sleep(1 * time.Second)
+		}
+	}()
+
+	// reload config on SIGHUP or when the file changes
+	reload := func() {
+		cfg, err := loader.Load()
 		if err != nil {
-			logger.Printf("sway.Subscribe error: %s", err)
+			logger.Printf("config not reloaded: %s", err)
+			return
+		}
+		err = eh.reload(ctx, cfg)
+		if err != nil {
+			logger.Printf("eh.reload: %s", err)
+			return
 		}
-		time.Sleep(1 * time.Second)
+		logger.Printf("config reloaded from %s", loader.Path())
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			reload()
+		}
+	}()
+
+	go loader.Watch(ctx, configPollInterval, reload)
+
+	// Graceful shutdown
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+
+	select {
+	case <-c:
+	case err = <-errc:
+	}
+
+	eh.scheduler.Stop()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	shutdownErr := sock.Shutdown(shutdownCtx)
+	if shutdownErr != nil {
+		logger.Printf("sock.Shutdown: %s", shutdownErr)
+	}
+
+	return err
+}
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "ctl" {
+		err := mainCtl()
+		if err != nil {
+			log.Fatalf("ctl: %s", err)
+		}
+		return
+	}
+
+	err := mainDaemon()
+	if err != nil {
+		log.Fatalf("reflex: %s", err)
 	}
 }
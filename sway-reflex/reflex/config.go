@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+
+	"github.com/kndndrj/sway-scripts/internal/core"
 )
 
 type Config struct {
@@ -16,28 +18,59 @@ type Config struct {
 	DefaultGapHorizontal int
 	DefaultGapVertical   int
 
+	// Forced split direction of the top level container.
+	// If nil, it is determined from the screen and window dimensions.
+	Direction *core.Direction
+
 	// List of disabled workspaces.
 	DisabledWorkspaces map[int]struct{}
+
+	// Per output and per workspace overrides.
+	// Rules are applied in order, so later ones take precedence.
+	Rules []*Rule
 }
 
-func ParseConfig() (*Config, error) {
-	prefferedWindowSize := flag.String("window_size", "500x300", "Preffered window size. <width>x<height> in [mm].")
-	defaultGaps := flag.Int("default_gaps", 0, "Default outer gaps [px].")
-	disabledWorkspaces := flag.String("disable_workspaces", "", "Comma-seperated list of workspace numbers to disable.")
+// Resolve returns the effective config for the workspace on the provided output
+// and reports if reflex is enabled on it.
+func (c *Config) Resolve(out *core.Output, workspaceName string, workspaceNum int) (*Config, bool) {
+	_, disabled := c.DisabledWorkspaces[workspaceNum]
+	enabled := !disabled
+
+	eff := *c
+	eff.Rules = nil
+	for _, r := range c.Rules {
+		if r.Matches(out, workspaceName, workspaceNum) {
+			r.apply(&eff, &enabled)
+		}
+	}
 
-	flag.Parse()
+	return &eff, enabled
+}
 
-	width, height, err := parseWindowSize(*prefferedWindowSize)
+// configFlags holds command line flags.
+type configFlags struct {
+	windowSize         string
+	defaultGaps        int
+	disabledWorkspaces string
+	rules              ruleFlag
+
+	// flags that were passed explicitly
+	set map[string]struct{}
+}
+
+// defaults returns the config built from flag values, regardless if they were set or not.
+func (f *configFlags) defaults() (*Config, error) {
+	width, height, err := parseWindowSize(f.windowSize)
 	if err != nil {
 		return nil, err
 	}
 
-	gaps, err := parseGaps(*defaultGaps)
+	gaps, err := parseGaps(f.defaultGaps)
 	if err != nil {
 		return nil, err
 	}
 
-	disabledWss, err := parseDisabledWorkspaces(*disabledWorkspaces)
+	disabledWss, err := parseDisabledWorkspaces(f.disabledWorkspaces)
 	if err != nil {
 		return nil, err
 	}
@@ -53,6 +86,66 @@ func ParseConfig() (*Config, error) {
 	}, nil
 }
 
+// apply overrides config values with flags that were passed explicitly.
+// Rules from flags are appended after existing ones.
+func (f *configFlags) apply(cfg *Config) error {
+	flagCfg, err := f.defaults()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := f.set["window_size"]; ok {
+		cfg.PhysicalWindowWidth = flagCfg.PhysicalWindowWidth
+		cfg.PhysicalWindowHeight = flagCfg.PhysicalWindowHeight
+	}
+	if _, ok := f.set["default_gaps"]; ok {
+		cfg.DefaultGapHorizontal = flagCfg.DefaultGapHorizontal
+		cfg.DefaultGapVertical = flagCfg.DefaultGapVertical
+	}
+	if _, ok := f.set["disable_workspaces"]; ok {
+		cfg.DisabledWorkspaces = flagCfg.DisabledWorkspaces
+	}
+	cfg.Rules = append(cfg.Rules, f.rules...)
+
+	return nil
+}
+
+// ParseConfigFlags parses command line flags and returns a loader
+// that combines them with the config file.
+func ParseConfigFlags() (*ConfigLoader, error) {
+	f := &configFlags{
+		set: make(map[string]struct{}),
+	}
+
+	configPath := flag.String("config", core.ConfigPath("sway-reflex"), "Path to the config file.")
+	flag.StringVar(&f.windowSize, "window_size", "500x300", "Preffered window size. <width>x<height> in [mm].")
+	flag.IntVar(&f.defaultGaps, "default_gaps", 0, "Default outer gaps [px].")
+	flag.StringVar(&f.disabledWorkspaces, "disable_workspaces", "", "Comma-seperated list of workspace numbers to disable.")
+	flag.Var(&f.rules, "rule", "Per output/workspace override, can be repeated. Comma-seperated <key>=<value> list.\n"+
+		"Matchers: output (name or \"<make> <model> <serial>\"), workspace, workspace_num.\n"+
+		"Overrides: window_size, gaps, direction (horizontal|vertical), enabled (true|false).")
+
+	flag.Parse()
+
+	flag.Visit(func(fl *flag.Flag) {
+		f.set[fl.Name] = struct{}{}
+	})
+
+	// validate flags early
+	_, err := f.defaults()
+	if err != nil {
+		return nil, err
+	}
+
+	_, required := f.set["config"]
+
+	return &ConfigLoader{
+		path:     *configPath,
+		required: required,
+		flags:    f,
+	}, nil
+}
+
 func parseWindowSize(in string) (w, h int, err error) {
 	input := strings.ToLower(in)
 
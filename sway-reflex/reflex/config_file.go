@@ -0,0 +1,238 @@
+package reflex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/kndndrj/sway-scripts/internal/core"
+)
+
+// fileWindowSize is a window size value in the config file: "<width>x<height>".
+type fileWindowSize struct {
+	width  int
+	height int
+}
+
+func (s *fileWindowSize) UnmarshalText(text []byte) error {
+	w, h, err := parseWindowSize(string(text))
+	if err != nil {
+		return err
+	}
+	s.width, s.height = w, h
+	return nil
+}
+
+// fileGaps is a gaps value in the config file.
+type fileGaps int
+
+func (g *fileGaps) UnmarshalText(text []byte) error {
+	var n int
+	_, err := fmt.Sscanf(string(text), "%d", &n)
+	if err != nil {
+		return fmt.Errorf("invalid gaps parameter: %q - not a number", text)
+	}
+	gaps, err := parseGaps(n)
+	if err != nil {
+		return err
+	}
+	*g = fileGaps(gaps)
+	return nil
+}
+
+// fileDirection is a direction value in the config file: "horizontal" or "vertical".
+type fileDirection core.Direction
+
+func (d *fileDirection) UnmarshalText(text []byte) error {
+	dir, err := parseDirection(string(text))
+	if err != nil {
+		return err
+	}
+	*d = fileDirection(dir)
+	return nil
+}
+
+type fileRule struct {
+	Output       string `toml:"output"`
+	Workspace    string `toml:"workspace"`
+	WorkspaceNum *int   `toml:"workspace_num"`
+
+	WindowSize     *fileWindowSize `toml:"window_size"`
+	Gaps           *fileGaps       `toml:"gaps"`
+	GapsHorizontal *fileGaps       `toml:"gaps_horizontal"`
+	GapsVertical   *fileGaps       `toml:"gaps_vertical"`
+	Direction      *fileDirection  `toml:"direction"`
+	Enabled        *bool           `toml:"enabled"`
+}
+
+// fileConfig is the layout of the config file.
+type fileConfig struct {
+	WindowSize            *fileWindowSize `toml:"window_size"`
+	DefaultGaps           *fileGaps       `toml:"default_gaps"`
+	DefaultGapsHorizontal *fileGaps       `toml:"default_gaps_horizontal"`
+	DefaultGapsVertical   *fileGaps       `toml:"default_gaps_vertical"`
+	Direction             *fileDirection  `toml:"direction"`
+	DisableWorkspaces     []int           `toml:"disable_workspaces"`
+
+	Rules []*fileRule `toml:"rule"`
+}
+
+func (r *fileRule) toRule() *Rule {
+	rule := &Rule{
+		Output:        r.Output,
+		WorkspaceName: r.Workspace,
+		WorkspaceNum:  r.WorkspaceNum,
+		Enabled:       r.Enabled,
+	}
+
+	if r.WindowSize != nil {
+		rule.PhysicalWindowWidth = &r.WindowSize.width
+		rule.PhysicalWindowHeight = &r.WindowSize.height
+	}
+	if r.Gaps != nil {
+		gaps := int(*r.Gaps)
+		rule.DefaultGapHorizontal, rule.DefaultGapVertical = &gaps, &gaps
+	}
+	if r.GapsHorizontal != nil {
+		gaps := int(*r.GapsHorizontal)
+		rule.DefaultGapHorizontal = &gaps
+	}
+	if r.GapsVertical != nil {
+		gaps := int(*r.GapsVertical)
+		rule.DefaultGapVertical = &gaps
+	}
+	if r.Direction != nil {
+		dir := core.Direction(*r.Direction)
+		rule.Direction = &dir
+	}
+
+	return rule
+}
+
+// apply overrides config values with the ones set in the file.
+func (f *fileConfig) apply(cfg *Config) {
+	if f.WindowSize != nil {
+		cfg.PhysicalWindowWidth = f.WindowSize.width
+		cfg.PhysicalWindowHeight = f.WindowSize.height
+	}
+	if f.DefaultGaps != nil {
+		cfg.DefaultGapHorizontal = int(*f.DefaultGaps)
+		cfg.DefaultGapVertical = int(*f.DefaultGaps)
+	}
+	if f.DefaultGapsHorizontal != nil {
+		cfg.DefaultGapHorizontal = int(*f.DefaultGapsHorizontal)
+	}
+	if f.DefaultGapsVertical != nil {
+		cfg.DefaultGapVertical = int(*f.DefaultGapsVertical)
+	}
+	if f.Direction != nil {
+		dir := core.Direction(*f.Direction)
+		cfg.Direction = &dir
+	}
+	if f.DisableWorkspaces != nil {
+		cfg.DisabledWorkspaces = make(map[int]struct{}, len(f.DisableWorkspaces))
+		for _, w := range f.DisableWorkspaces {
+			cfg.DisabledWorkspaces[w] = struct{}{}
+		}
+	}
+	for _, r := range f.Rules {
+		cfg.Rules = append(cfg.Rules, r.toRule())
+	}
+}
+
+// parseConfigFile decodes and validates the config file contents.
+func parseConfigFile(path string, data []byte) (*fileConfig, error) {
+	var f fileConfig
+	err := core.DecodeTOML(path, data, &f)
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// ConfigLoader builds the config from the config file and command line flags.
+// Flags passed explicitly take precedence over the file.
+type ConfigLoader struct {
+	path string
+	// path was passed explicitly, so the file has to exist.
+	required bool
+
+	flags *configFlags
+}
+
+// Path returns the path of the config file.
+func (l *ConfigLoader) Path() string {
+	return l.path
+}
+
+// Load reads the config file (if it exists) and applies command line flags on top of it.
+func (l *ConfigLoader) Load() (*Config, error) {
+	cfg, err := l.flags.defaults()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) || l.required {
+			return nil, fmt.Errorf("os.ReadFile: %w", err)
+		}
+	} else {
+		f, err := parseConfigFile(l.path, data)
+		if err != nil {
+			return nil, err
+		}
+		f.apply(cfg)
+	}
+
+	err = l.flags.apply(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// fileState identifies a version of the config file.
+type fileState struct {
+	exists  bool
+	modTime time.Time
+	size    int64
+}
+
+func (l *ConfigLoader) stat() fileState {
+	info, err := os.Stat(l.path)
+	if err != nil {
+		return fileState{}
+	}
+	return fileState{
+		exists:  true,
+		modTime: info.ModTime(),
+		size:    info.Size(),
+	}
+}
+
+// Watch polls the config file and calls onChange every time it is created,
+// modified or removed. It blocks until the context is cancelled.
+func (l *ConfigLoader) Watch(ctx context.Context, interval time.Duration, onChange func()) {
+	last := l.stat()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		current := l.stat()
+		if current != last {
+			last = current
+			onChange()
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package reflex
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kndndrj/sway-scripts/internal/core"
+)
+
+func TestConfig_Resolve(t *testing.T) {
+	laptop := &core.Output{Name: "eDP-1", Make: "BOE", Model: "0x095F", Serial: "Unknown"}
+	ultrawide := &core.Output{Name: "DP-1", Make: "Dell Inc.", Model: "DELL U4919DW", Serial: "ABC123"}
+
+	mustParseRule := func(in string) *Rule {
+		r, err := parseRule(in)
+		require.NoError(t, err)
+		return r
+	}
+
+	cfg := &Config{
+		PhysicalWindowWidth:  500,
+		PhysicalWindowHeight: 300,
+		DefaultGapHorizontal: 10,
+		DefaultGapVertical:   10,
+		DisabledWorkspaces:   map[int]struct{}{9: {}},
+		Rules: []*Rule{
+			mustParseRule("output=eDP-1,window_size=200x150,gaps=0"),
+			mustParseRule("output=Dell Inc. DELL U4919DW ABC123,direction=horizontal"),
+			mustParseRule("workspace=2:code,window_size=700x400"),
+			mustParseRule("workspace_num=9,output=DP-1,enabled=true"),
+			mustParseRule("workspace_num=5,enabled=false"),
+		},
+	}
+
+	horizontal := core.DirectionHorizontal
+
+	testCases := []struct {
+		comment       string
+		out           *core.Output
+		workspaceName string
+		workspaceNum  int
+
+		expectedWidth     int
+		expectedHeight    int
+		expectedGaps      int
+		expectedDirection *core.Direction
+		expectedEnabled   bool
+	}{
+		{
+			comment:         "output name rule",
+			out:             laptop,
+			workspaceName:   "1",
+			workspaceNum:    1,
+			expectedWidth:   200,
+			expectedHeight:  150,
+			expectedGaps:    0,
+			expectedEnabled: true,
+		},
+		{
+			comment:           "output identifier rule",
+			out:               ultrawide,
+			workspaceName:     "1",
+			workspaceNum:      1,
+			expectedWidth:     500,
+			expectedHeight:    300,
+			expectedGaps:      10,
+			expectedDirection: &horizontal,
+			expectedEnabled:   true,
+		},
+		{
+			comment:         "later workspace rule overrides output rule",
+			out:             laptop,
+			workspaceName:   "2:code",
+			workspaceNum:    2,
+			expectedWidth:   700,
+			expectedHeight:  400,
+			expectedGaps:    0,
+			expectedEnabled: true,
+		},
+		{
+			comment:         "disabled workspace stays disabled on other outputs",
+			out:             laptop,
+			workspaceName:   "9",
+			workspaceNum:    9,
+			expectedWidth:   200,
+			expectedHeight:  150,
+			expectedGaps:    0,
+			expectedEnabled: false,
+		},
+		{
+			comment:           "rule re-enables disabled workspace",
+			out:               ultrawide,
+			workspaceName:     "9",
+			workspaceNum:      9,
+			expectedWidth:     500,
+			expectedHeight:    300,
+			expectedGaps:      10,
+			expectedDirection: &horizontal,
+			expectedEnabled:   true,
+		},
+		{
+			comment:           "rule disables workspace",
+			out:               ultrawide,
+			workspaceName:     "5",
+			workspaceNum:      5,
+			expectedWidth:     500,
+			expectedHeight:    300,
+			expectedGaps:      10,
+			expectedDirection: &horizontal,
+			expectedEnabled:   false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.comment, func(t *testing.T) {
+			r := require.New(t)
+
+			eff, enabled := cfg.Resolve(tc.out, tc.workspaceName, tc.workspaceNum)
+
+			r.Equal(tc.expectedWidth, eff.PhysicalWindowWidth)
+			r.Equal(tc.expectedHeight, eff.PhysicalWindowHeight)
+			r.Equal(tc.expectedGaps, eff.DefaultGapHorizontal)
+			r.Equal(tc.expectedGaps, eff.DefaultGapVertical)
+			r.Equal(tc.expectedDirection, eff.Direction)
+			r.Equal(tc.expectedEnabled, enabled)
+			r.Nil(eff.Rules)
+		})
+	}
+
+	// original config is left untouched
+	require.Equal(t, 500, cfg.PhysicalWindowWidth)
+	require.Nil(t, cfg.Direction)
+}
+
+func TestParseRule_Invalid(t *testing.T) {
+	for _, in := range []string{
+		"output",
+		"unknown=1",
+		"workspace_num=two",
+		"window_size=400",
+		"gaps=-1",
+		"direction=diagonal",
+		"enabled=maybe",
+	} {
+		_, err := parseRule(in)
+		require.Error(t, err, in)
+	}
+}
+
+func TestConfigLoader_Load(t *testing.T) {
+	r := require.New(t)
+
+	path := filepath.Join(t.TempDir(), "config")
+	err := os.WriteFile(path, []byte(`
+window_size = "400x250"
+default_gaps = 10
+default_gaps_vertical = 5
+disable_workspaces = [8, 9]
+
+[[rule]]
+output = "eDP-1"
+window_size = "200x150"
+direction = "vertical"
+
+[[rule]]
+workspace_num = 9
+enabled = true
+`), 0o644)
+	r.NoError(err)
+
+	loader := &ConfigLoader{
+		path:     path,
+		required: true,
+		flags: &configFlags{
+			windowSize: "500x300",
+			// set flags override the file
+			disabledWorkspaces: "3",
+			rules:              ruleFlag{{Output: "DP-1"}},
+			set:                map[string]struct{}{"disable_workspaces": {}, "rule": {}},
+		},
+	}
+
+	cfg, err := loader.Load()
+	r.NoError(err)
+
+	vertical := core.DirectionVertical
+
+	r.Equal(400, cfg.PhysicalWindowWidth)
+	r.Equal(250, cfg.PhysicalWindowHeight)
+	r.Equal(10, cfg.DefaultGapHorizontal)
+	r.Equal(5, cfg.DefaultGapVertical)
+	r.Nil(cfg.Direction)
+	r.Equal(map[int]struct{}{3: {}}, cfg.DisabledWorkspaces)
+	r.Len(cfg.Rules, 3)
+	r.Equal("eDP-1", cfg.Rules[0].Output)
+	r.Equal(200, *cfg.Rules[0].PhysicalWindowWidth)
+	r.Equal(&vertical, cfg.Rules[0].Direction)
+	r.Equal(9, *cfg.Rules[1].WorkspaceNum)
+	r.True(*cfg.Rules[1].Enabled)
+	r.Equal("DP-1", cfg.Rules[2].Output)
+
+	// missing default file falls back to flags
+	loader.path = filepath.Join(t.TempDir(), "missing")
+	loader.required = false
+	cfg, err = loader.Load()
+	r.NoError(err)
+	r.Equal(500, cfg.PhysicalWindowWidth)
+}
+
+func TestParseConfigFile_Errors(t *testing.T) {
+	testCases := []struct {
+		comment  string
+		contents string
+		expected string
+	}{
+		{
+			comment:  "syntax error",
+			contents: "default_gaps = 1\nwindow_size = \n",
+			expected: "config:2:",
+		},
+		{
+			comment:  "invalid window size",
+			contents: "default_gaps = 1\n\n[[rule]]\noutput = \"DP-1\"\nwindow_size = \"400\"\n",
+			expected: "config:5: rule.window_size: invlid window size format",
+		},
+		{
+			comment:  "negative gaps",
+			contents: "default_gaps = -3\n",
+			expected: "config:1: default_gaps: invalid default gaps parameter",
+		},
+		{
+			comment:  "invalid direction",
+			contents: "\ndirection = \"diagonal\"\n",
+			expected: "config:2: direction: invalid direction",
+		},
+		{
+			comment:  "unknown key",
+			contents: "default_gaps = 1\n[[rule]]\noutptu = \"DP-1\"\n",
+			expected: "config:3: unknown key: \"rule.outptu\"",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.comment, func(t *testing.T) {
+			_, err := parseConfigFile("config", []byte(tc.contents))
+			require.ErrorContains(t, err, tc.expected)
+		})
+	}
+}
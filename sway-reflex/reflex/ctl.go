@@ -0,0 +1,165 @@
+package reflex
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Command is an action requested over the control socket.
+type Command string
+
+const (
+	CommandEnable  Command = "enable"
+	CommandDisable Command = "disable"
+	CommandToggle  Command = "toggle"
+	CommandStatus  Command = "status"
+
+	CommandGrow      Command = "grow"
+	CommandShrink    Command = "shrink"
+	CommandSize      Command = "size"
+	CommandResetSize Command = "reset-size"
+)
+
+func parseCommand(in string) (Command, error) {
+	cmd := Command(strings.ToLower(in))
+
+	switch cmd {
+	case CommandEnable, CommandDisable, CommandToggle, CommandStatus,
+		CommandGrow, CommandShrink, CommandSize, CommandResetSize:
+		return cmd, nil
+	}
+
+	return "", fmt.Errorf("unknown command: %q", in)
+}
+
+// Dimension selects which side of the preffered window size is adjusted.
+type Dimension string
+
+const (
+	DimensionWidth  Dimension = "width"
+	DimensionHeight Dimension = "height"
+)
+
+func parseDimension(in string) (Dimension, error) {
+	dim := Dimension(strings.ToLower(in))
+
+	switch dim {
+	case DimensionWidth, DimensionHeight:
+		return dim, nil
+	}
+
+	return "", fmt.Errorf("invalid dimension: %q - should be width or height", in)
+}
+
+// CtlConfig holds arguments of the "ctl" subcommand.
+type CtlConfig struct {
+	Command Command
+	// Workspace name or number. Empty means the focused workspace.
+	Workspace string
+
+	// grow/shrink arguments
+	Dimension Dimension
+	Amount    int
+
+	// size arguments in [mm]
+	WindowWidth  int
+	WindowHeight int
+}
+
+const ctlUsage = `expected one of:
+  enable|disable|toggle|status [workspace]
+  grow|shrink width|height <mm> [workspace]
+  size <width>x<height> [workspace]
+  reset-size [workspace]`
+
+// ParseCtlArgs parses arguments following the "ctl" subcommand.
+func ParseCtlArgs(args []string) (*CtlConfig, error) {
+	if len(args) < 1 {
+		return nil, errors.New(ctlUsage)
+	}
+
+	cmd, err := parseCommand(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("%w\n%s", err, ctlUsage)
+	}
+
+	cfg := &CtlConfig{
+		Command: cmd,
+	}
+
+	// positional arguments specific to the command
+	rest := args[1:]
+	switch cmd {
+	case CommandGrow, CommandShrink:
+		if len(rest) < 2 {
+			return nil, fmt.Errorf("%s: expected a dimension and amount in [mm]", cmd)
+		}
+		cfg.Dimension, err = parseDimension(rest[0])
+		if err != nil {
+			return nil, err
+		}
+		cfg.Amount, err = strconv.Atoi(rest[1])
+		if err != nil || cfg.Amount < 1 {
+			return nil, fmt.Errorf("invalid amount: %q - should be a positive integer", rest[1])
+		}
+		rest = rest[2:]
+	case CommandSize:
+		if len(rest) < 1 {
+			return nil, fmt.Errorf("%s: expected window size <width>x<height> in [mm]", cmd)
+		}
+		cfg.WindowWidth, cfg.WindowHeight, err = parseWindowSize(rest[0])
+		if err != nil {
+			return nil, err
+		}
+		rest = rest[1:]
+	}
+
+	if len(rest) > 1 {
+		return nil, fmt.Errorf("too many arguments: %q", rest[1:])
+	}
+	if len(rest) == 1 {
+		cfg.Workspace = rest[0]
+	}
+
+	return cfg, nil
+}
+
+// WindowSize is a preffered physical window size in [mm].
+type WindowSize struct {
+	Width  int
+	Height int
+}
+
+// ResizeWindow applies a size command to the current window size. Grow and shrink adjust
+// one dimension by the amount (never below 1mm), size sets both to the requested size.
+// Nil is returned for reset-size - the configured size applies again.
+func ResizeWindow(current WindowSize, cmd Command, dim Dimension, amount int, requested WindowSize) (*WindowSize, error) {
+	switch cmd {
+	case CommandGrow, CommandShrink:
+		delta := amount
+		if cmd == CommandShrink {
+			delta = -delta
+		}
+		size := current
+		switch dim {
+		case DimensionWidth:
+			size.Width = max(size.Width+delta, 1)
+		case DimensionHeight:
+			size.Height = max(size.Height+delta, 1)
+		default:
+			return nil, fmt.Errorf("invalid dimension: %q", dim)
+		}
+		return &size, nil
+	case CommandSize:
+		if requested.Width < 1 || requested.Height < 1 {
+			return nil, fmt.Errorf("invalid window size: %dx%d", requested.Width, requested.Height)
+		}
+		return &requested, nil
+	case CommandResetSize:
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("not a size command: %q", cmd)
+}
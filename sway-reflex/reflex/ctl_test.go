@@ -0,0 +1,249 @@
+package reflex
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCtlArgs(t *testing.T) {
+	testCases := []struct {
+		comment  string
+		args     []string
+		expected *CtlConfig
+	}{
+		{
+			comment:  "status of the focused workspace",
+			args:     []string{"status"},
+			expected: &CtlConfig{Command: CommandStatus},
+		},
+		{
+			comment:  "verbs are case insensitive",
+			args:     []string{"Toggle"},
+			expected: &CtlConfig{Command: CommandToggle},
+		},
+		{
+			comment:  "workspace selected by number",
+			args:     []string{"enable", "3"},
+			expected: &CtlConfig{Command: CommandEnable, Workspace: "3"},
+		},
+		{
+			comment:  "workspace selected by name",
+			args:     []string{"disable", "2:code"},
+			expected: &CtlConfig{Command: CommandDisable, Workspace: "2:code"},
+		},
+		{
+			comment:  "grow width",
+			args:     []string{"grow", "width", "20"},
+			expected: &CtlConfig{Command: CommandGrow, Dimension: DimensionWidth, Amount: 20},
+		},
+		{
+			comment:  "shrink height on a workspace",
+			args:     []string{"shrink", "HEIGHT", "5", "4"},
+			expected: &CtlConfig{Command: CommandShrink, Dimension: DimensionHeight, Amount: 5, Workspace: "4"},
+		},
+		{
+			comment:  "size",
+			args:     []string{"size", "400x250"},
+			expected: &CtlConfig{Command: CommandSize, WindowWidth: 400, WindowHeight: 250},
+		},
+		{
+			comment:  "size on a workspace",
+			args:     []string{"size", "400X250", "web"},
+			expected: &CtlConfig{Command: CommandSize, WindowWidth: 400, WindowHeight: 250, Workspace: "web"},
+		},
+		{
+			comment:  "reset size on a workspace",
+			args:     []string{"reset-size", "1"},
+			expected: &CtlConfig{Command: CommandResetSize, Workspace: "1"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.comment, func(t *testing.T) {
+			cfg, err := ParseCtlArgs(tc.args)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, cfg)
+		})
+	}
+}
+
+func TestParseCtlArgs_Invalid(t *testing.T) {
+	testCases := []struct {
+		comment  string
+		args     []string
+		expected string
+	}{
+		{
+			comment:  "no verb",
+			args:     nil,
+			expected: "expected one of",
+		},
+		{
+			comment:  "unknown verb",
+			args:     []string{"restart"},
+			expected: "unknown command: \"restart\"",
+		},
+		{
+			comment:  "too many workspaces",
+			args:     []string{"status", "1", "2"},
+			expected: "too many arguments",
+		},
+		{
+			comment:  "grow without amount",
+			args:     []string{"grow", "width"},
+			expected: "grow: expected a dimension and amount",
+		},
+		{
+			comment:  "invalid dimension",
+			args:     []string{"grow", "depth", "10"},
+			expected: "invalid dimension: \"depth\"",
+		},
+		{
+			comment:  "amount is not a number",
+			args:     []string{"shrink", "width", "ten"},
+			expected: "invalid amount: \"ten\"",
+		},
+		{
+			comment:  "amount is not positive",
+			args:     []string{"shrink", "height", "0"},
+			expected: "invalid amount: \"0\"",
+		},
+		{
+			comment:  "amount is followed by too many arguments",
+			args:     []string{"grow", "width", "10", "1", "2"},
+			expected: "too many arguments",
+		},
+		{
+			comment:  "size without value",
+			args:     []string{"size"},
+			expected: "size: expected window size",
+		},
+		{
+			comment:  "size without height",
+			args:     []string{"size", "400"},
+			expected: "invlid window size format",
+		},
+		{
+			comment:  "size is not a number",
+			args:     []string{"size", "widex250"},
+			expected: "invalid width parameter",
+		},
+		{
+			comment:  "reset size of two workspaces",
+			args:     []string{"reset-size", "1", "2"},
+			expected: "too many arguments",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.comment, func(t *testing.T) {
+			_, err := ParseCtlArgs(tc.args)
+			require.ErrorContains(t, err, tc.expected)
+		})
+	}
+}
+
+func TestResizeWindow(t *testing.T) {
+	current := WindowSize{Width: 500, Height: 300}
+
+	testCases := []struct {
+		comment   string
+		cmd       Command
+		dimension Dimension
+		amount    int
+		requested WindowSize
+		expected  *WindowSize
+	}{
+		{
+			comment:   "grow width",
+			cmd:       CommandGrow,
+			dimension: DimensionWidth,
+			amount:    20,
+			expected:  &WindowSize{Width: 520, Height: 300},
+		},
+		{
+			comment:   "shrink height",
+			cmd:       CommandShrink,
+			dimension: DimensionHeight,
+			amount:    50,
+			expected:  &WindowSize{Width: 500, Height: 250},
+		},
+		{
+			comment:   "shrink is clamped to 1mm",
+			cmd:       CommandShrink,
+			dimension: DimensionWidth,
+			amount:    1000,
+			expected:  &WindowSize{Width: 1, Height: 300},
+		},
+		{
+			comment:   "shrink to exactly 1mm",
+			cmd:       CommandShrink,
+			dimension: DimensionHeight,
+			amount:    299,
+			expected:  &WindowSize{Width: 500, Height: 1},
+		},
+		{
+			comment:   "size replaces both dimensions",
+			cmd:       CommandSize,
+			requested: WindowSize{Width: 400, Height: 250},
+			expected:  &WindowSize{Width: 400, Height: 250},
+		},
+		{
+			comment:  "reset size",
+			cmd:      CommandResetSize,
+			expected: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.comment, func(t *testing.T) {
+			size, err := ResizeWindow(current, tc.cmd, tc.dimension, tc.amount, tc.requested)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, size)
+		})
+	}
+}
+
+func TestResizeWindow_Invalid(t *testing.T) {
+	current := WindowSize{Width: 500, Height: 300}
+
+	testCases := []struct {
+		comment   string
+		cmd       Command
+		dimension Dimension
+		requested WindowSize
+		expected  string
+	}{
+		{
+			comment:   "invalid dimension",
+			cmd:       CommandGrow,
+			dimension: "depth",
+			expected:  "invalid dimension",
+		},
+		{
+			comment:   "zero width",
+			cmd:       CommandSize,
+			requested: WindowSize{Width: 0, Height: 250},
+			expected:  "invalid window size: 0x250",
+		},
+		{
+			comment:   "negative height",
+			cmd:       CommandSize,
+			requested: WindowSize{Width: 400, Height: -1},
+			expected:  "invalid window size: 400x-1",
+		},
+		{
+			comment:  "not a size command",
+			cmd:      CommandToggle,
+			expected: "not a size command",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.comment, func(t *testing.T) {
+			_, err := ResizeWindow(current, tc.cmd, tc.dimension, 10, tc.requested)
+			require.ErrorContains(t, err, tc.expected)
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package reflex
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/kndndrj/sway-scripts/internal/core"
+)
+
+// Rule overrides global settings for matching outputs and workspaces.
+// Empty matchers match everything, nil overrides leave the value unchanged.
+type Rule struct {
+	// Output name (e.g. "DP-1") or identifier ("<make> <model> <serial>").
+	Output        string
+	WorkspaceName string
+	WorkspaceNum  *int
+
+	PhysicalWindowWidth  *int
+	PhysicalWindowHeight *int
+	DefaultGapHorizontal *int
+	DefaultGapVertical   *int
+	Direction            *core.Direction
+	Enabled              *bool
+}
+
+// Matches returns true if rule applies to the workspace on the provided output.
+func (r *Rule) Matches(out *core.Output, workspaceName string, workspaceNum int) bool {
+	if r.Output != "" && r.Output != out.Name && r.Output != out.Identifier() {
+		return false
+	}
+	if r.WorkspaceName != "" && r.WorkspaceName != workspaceName {
+		return false
+	}
+	if r.WorkspaceNum != nil && *r.WorkspaceNum != workspaceNum {
+		return false
+	}
+	return true
+}
+
+// apply overrides the config values with the ones set in rule.
+func (r *Rule) apply(cfg *Config, enabled *bool) {
+	if r.PhysicalWindowWidth != nil {
+		cfg.PhysicalWindowWidth = *r.PhysicalWindowWidth
+	}
+	if r.PhysicalWindowHeight != nil {
+		cfg.PhysicalWindowHeight = *r.PhysicalWindowHeight
+	}
+	if r.DefaultGapHorizontal != nil {
+		cfg.DefaultGapHorizontal = *r.DefaultGapHorizontal
+	}
+	if r.DefaultGapVertical != nil {
+		cfg.DefaultGapVertical = *r.DefaultGapVertical
+	}
+	if r.Direction != nil {
+		cfg.Direction = r.Direction
+	}
+	if r.Enabled != nil {
+		*enabled = *r.Enabled
+	}
+}
+
+func parseDirection(in string) (core.Direction, error) {
+	switch strings.ToLower(in) {
+	case "horizontal":
+		return core.DirectionHorizontal, nil
+	case "vertical":
+		return core.DirectionVertical, nil
+	}
+	return 0, fmt.Errorf("invalid direction: %q - should be horizontal or vertical", in)
+}
+
+// parseRule parses a comma-separated list of key=value pairs.
+// example: "output=DP-1,workspace_num=2,window_size=400x300,gaps=10,direction=vertical,enabled=false"
+func parseRule(in string) (*Rule, error) {
+	r := new(Rule)
+
+	for _, pair := range strings.Split(in, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid rule item: %q - should be <key>=<value>", pair)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "output":
+			r.Output = value
+		case "workspace":
+			r.WorkspaceName = value
+		case "workspace_num":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid workspace number: %q - not a number", value)
+			}
+			r.WorkspaceNum = &n
+		case "window_size":
+			w, h, err := parseWindowSize(value)
+			if err != nil {
+				return nil, err
+			}
+			r.PhysicalWindowWidth, r.PhysicalWindowHeight = &w, &h
+		case "gaps":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid gaps: %q - not a number", value)
+			}
+			gaps, err := parseGaps(n)
+			if err != nil {
+				return nil, err
+			}
+			r.DefaultGapHorizontal, r.DefaultGapVertical = &gaps, &gaps
+		case "direction":
+			dir, err := parseDirection(value)
+			if err != nil {
+				return nil, err
+			}
+			r.Direction = &dir
+		case "enabled":
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid enabled value: %q - should be true or false", value)
+			}
+			r.Enabled = &b
+		default:
+			return nil, fmt.Errorf("unknown rule key: %q", key)
+		}
+	}
+
+	return r, nil
+}
+
+// ruleFlag collects repeated -rule flags.
+type ruleFlag []*Rule
+
+func (f *ruleFlag) String() string {
+	return fmt.Sprintf("%d rules", len(*f))
+}
+
+func (f *ruleFlag) Set(in string) error {
+	r, err := parseRule(in)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, r)
+	return nil
+}
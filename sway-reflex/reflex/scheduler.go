@@ -0,0 +1,73 @@
+package reflex
+
+import (
+	"sync"
+	"time"
+)
+
+// Scheduler coalesces layout requests per workspace. The first request for a workspace
+// opens a window of the configured delay, all requests that arrive within it are merged
+// into a single layout pass run at its end.
+type Scheduler struct {
+	delay time.Duration
+	run   func(workspace string, closed bool)
+
+	mu sync.Mutex
+	// workspaces waiting for a pass and whether a window was closed on them
+	pending map[string]bool
+	stopped bool
+}
+
+// NewScheduler creates a scheduler that calls run for each coalesced batch of requests.
+// Passes of different workspaces might run concurrently.
+func NewScheduler(delay time.Duration, run func(workspace string, closed bool)) *Scheduler {
+	return &Scheduler{
+		delay:   delay,
+		run:     run,
+		pending: make(map[string]bool),
+	}
+}
+
+// Schedule requests a layout pass of the workspace.
+// closed reports that a window was closed - the pass gets it if any of the merged requests had it.
+func (s *Scheduler) Schedule(workspace string, closed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopped {
+		return
+	}
+
+	if prev, ok := s.pending[workspace]; ok {
+		s.pending[workspace] = prev || closed
+		return
+	}
+
+	s.pending[workspace] = closed
+	time.AfterFunc(s.delay, func() {
+		s.fire(workspace)
+	})
+}
+
+func (s *Scheduler) fire(workspace string) {
+	s.mu.Lock()
+	closed, ok := s.pending[workspace]
+	delete(s.pending, workspace)
+	stopped := s.stopped
+	s.mu.Unlock()
+
+	if !ok || stopped {
+		return
+	}
+
+	s.run(workspace, closed)
+}
+
+// Stop drops pending requests and ignores new ones.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stopped = true
+	clear(s.pending)
+}
@@ -0,0 +1,64 @@
+package reflex
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type pass struct {
+	workspace string
+	closed    bool
+}
+
+func TestScheduler(t *testing.T) {
+	r := require.New(t)
+
+	passes := make(chan pass, 16)
+	s := NewScheduler(50*time.Millisecond, func(workspace string, closed bool) {
+		passes <- pass{workspace: workspace, closed: closed}
+	})
+
+	next := func() pass {
+		select {
+		case p := <-passes:
+			return p
+		case <-time.After(5 * time.Second):
+			r.FailNow("no layout pass")
+			return pass{}
+		}
+	}
+	none := func() {
+		select {
+		case p := <-passes:
+			r.FailNow("unexpected layout pass", "%+v", p)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	// burst on one workspace is a single pass, closing is not lost
+	s.Schedule("1", false)
+	s.Schedule("1", true)
+	s.Schedule("1", false)
+	r.Equal(pass{workspace: "1", closed: true}, next())
+	none()
+
+	// workspaces are coalesced separately
+	s.Schedule("1", false)
+	s.Schedule("2", false)
+	s.Schedule("1", false)
+	got := []pass{next(), next()}
+	r.ElementsMatch([]pass{{workspace: "1"}, {workspace: "2"}}, got)
+	none()
+
+	// requests after a pass start a new window
+	s.Schedule("1", false)
+	r.Equal(pass{workspace: "1"}, next())
+
+	// stopped scheduler drops pending requests
+	s.Schedule("1", false)
+	s.Stop()
+	s.Schedule("2", false)
+	none()
+}
@@ -46,7 +46,9 @@ func NewScreen(out *core.Output, cfg *Config) *Screen {
 	height := out.Height - (cfg.DefaultGapVertical * 2)
 
 	dir := core.DirectionHorizontal
-	if height-prefferedWindowHeight > width-prefferedWindowWidth {
+	if cfg.Direction != nil {
+		dir = *cfg.Direction
+	} else if height-prefferedWindowHeight > width-prefferedWindowWidth {
 		dir = core.DirectionVertical
 	}
 
@@ -2,12 +2,14 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/joshuarubin/go-sway"
 
@@ -33,12 +35,25 @@ func newEventHandler(logger *log.Logger, server *scratch.Server) *eventHandler {
 
 // Window handler gets called on window events.
 func (eh *eventHandler) Window(ctx context.Context, e sway.WindowEvent) {
-	err := eh.server.OnWindow(ctx)
+	err := eh.server.OnWindow(ctx, e)
 	if err != nil {
 		eh.log.Printf("OnWindow: %s", err)
 	}
 }
 
+// Tick handler gets called on tick events.
+// The first one is sent right after subscribing, so windows of prestarted scratchpads can't be missed.
+func (eh *eventHandler) Tick(ctx context.Context, e sway.TickEvent) {
+	if !e.First {
+		return
+	}
+
+	err := eh.server.Prestart(ctx)
+	if err != nil {
+		eh.log.Printf("Prestart: %s", err)
+	}
+}
+
 // Workspace handler gets called on workspace events.
 func (eh *eventHandler) Workspace(ctx context.Context, e sway.WorkspaceEvent) {
 	err := eh.server.OnWorkspace(ctx)
@@ -48,12 +63,42 @@ func (eh *eventHandler) Workspace(ctx context.Context, e sway.WorkspaceEvent) {
 }
 
 // socketMessage is passed throught the unix socket.
+// Definition is nil for scratchpads from the definitions file.
+// Caller is the working directory and environment of the client (nil if not sent).
 type socketMessage struct {
+	Command    scratch.Command
 	ID         string
 	Definition *scratch.Definition
+	Caller     *scratch.Caller
+	// Follow keeps the logs stream open.
+	Follow bool
+}
+
+// streamEvents sends scratchpad events to the subscriber until it hangs up.
+func streamEvents(ctx context.Context, server *scratch.Server, send func(any) error) error {
+	events, unsubscribe := server.Subscribe()
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e, ok := <-events:
+			if !ok {
+				return errors.New("subscriber dropped: too slow")
+			}
+			err := send(e)
+			if err != nil {
+				return err
+			}
+		}
+	}
 }
 
-const socketName = "sway_scratch"
+const (
+	socketName      = "sway_scratch"
+	shutdownTimeout = 5 * time.Second
+)
 
 // mainServer is a main function for server mode.
 func mainServer() error {
@@ -61,8 +106,18 @@ func mainServer() error {
 
 	logger := log.New(os.Stdout, "scratch: ", log.LstdFlags)
 
+	cfg, err := scratch.ParseServeFlags()
+	if err != nil {
+		return err
+	}
+
+	defs, err := scratch.LoadDefinitions(cfg.DefinitionsPath, cfg.DefinitionsRequired)
+	if err != nil {
+		return fmt.Errorf("scratch.LoadDefinitions: %w", err)
+	}
+
 	// check pidfile
-	err := core.LockPidFile("sway_scratch")
+	err = core.LockPidFile("sway_scratch")
 	if err != nil {
 		if errors.Is(err, core.ErrProcessAlreadyRunning) {
 			return fmt.Errorf("server already running")
@@ -83,59 +138,141 @@ func mainServer() error {
 
 	// server that manages scratchpads
 	server := scratch.NewServer(logger, client, core.NewOutputCache(client), core.NewNodeNinja(client))
+	server.SetDefinitions(defs)
+	server.SetLogDir(core.StateDir("sway-scratch"))
 
 	// event handler for sway events
 	events := newEventHandler(logger, server)
 
 	// socket server for requests over the socket
-	sock, err := socket.NewServer(logger, socketName, func(ctx context.Context, msg *socketMessage) error {
-		return server.ToggleScratchpad(ctx, msg.ID, msg.Definition)
+	sock, err := socket.NewServer(logger, socketName, func(ctx context.Context, msg *socketMessage) (any, error) {
+		switch msg.Command {
+		case scratch.CommandList:
+			return server.List(), nil
+		case scratch.CommandSubscribe:
+			return socket.Stream(func(ctx context.Context, send func(any) error) error {
+				return streamEvents(ctx, server, send)
+			}), nil
+		case scratch.CommandLogs:
+			path, err := server.LogPath(msg.ID)
+			if err != nil {
+				return nil, err
+			}
+			return socket.Stream(func(ctx context.Context, send func(any) error) error {
+				return scratch.TailLog(ctx, path, msg.Follow, func(line string) error {
+					return send(line)
+				})
+			}), nil
+		}
+		return nil, server.RunFrom(ctx, msg.Caller, msg.Command, msg.ID, msg.Definition)
 	})
 	if err != nil {
 		return fmt.Errorf("socket.NewServer: %w", err)
 	}
-	defer sock.Close()
+
+	errc := make(chan error, 2)
 
 	// start socket handler
 	go func() {
 		err := sock.Serve(ctx)
-		if err != nil {
-			logger.Fatalf("sock.Serve: %s", err)
+		if err != nil && !errors.Is(err, socket.ErrServerClosed) {
+			errc <- fmt.Errorf("sock.Serve: %w", err)
 		}
 	}()
 
 	// start event handler
 	go func() {
-		err = sway.Subscribe(ctx, events, sway.EventTypeWindow, sway.EventTypeWorkspace)
+		err := sway.Subscribe(ctx, events, sway.EventTypeWindow, sway.EventTypeWorkspace, sway.EventTypeTick)
 		if err != nil {
-			logger.Fatalf("sway.Subscribe: %s", err)
+			errc <- fmt.Errorf("sway.Subscribe: %w", err)
+		}
+	}()
+
+	// reload definitions on SIGHUP
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			defs, err := scratch.LoadDefinitions(cfg.DefinitionsPath, cfg.DefinitionsRequired)
+			if err != nil {
+				logger.Printf("definitions not reloaded: %s", err)
+				continue
+			}
+			server.SetDefinitions(defs)
+			logger.Printf("definitions reloaded from %s", cfg.DefinitionsPath)
+
+			err = server.Prestart(ctx)
+			if err != nil {
+				logger.Printf("Prestart: %s", err)
+			}
 		}
 	}()
 
 	// Graceful shutdown
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-	<-c
 
-	return nil
+	select {
+	case <-c:
+	case err = <-errc:
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	shutdownErr := sock.Shutdown(shutdownCtx)
+	if shutdownErr != nil {
+		logger.Printf("sock.Shutdown: %s", shutdownErr)
+	}
+
+	return err
 }
 
-// mainServer is a main function for call mode.
-func mainCall() error {
-	cfg, err := scratch.ParseCallFlags()
+// mainCall is a main function for subcommands addressing a single scratchpad.
+func mainCall(subcmd scratch.Subcommand) error {
+	cfg, err := scratch.ParseCallFlags(subcmd)
 	if err != nil {
 		return err
 	}
-	err = socket.Invoke(socketName,
-		&socketMessage{
-			ID: cfg.ID,
-			Definition: &scratch.Definition{
-				Position:     cfg.Position,
-				Cmd:          cfg.Cmd,
-				WindowWidth:  cfg.WindowWidth,
-				WindowHeight: cfg.WindowHeight,
-			},
-		})
+	msg := &socketMessage{
+		Command: cfg.Command,
+		ID:      cfg.ID,
+	}
+	if cfg.Inline {
+		msg.Definition = &scratch.Definition{
+			Position:      cfg.Position,
+			Cmd:           cfg.Cmd,
+			WindowWidth:   cfg.WindowWidth,
+			WindowHeight:  cfg.WindowHeight,
+			Margin:        cfg.Margin,
+			Criteria:      cfg.Criteria,
+			ClaimExisting: cfg.ClaimExisting,
+			Group:         cfg.Group,
+			Respawn:       cfg.Respawn,
+			CloseOnHide:   cfg.CloseOnHide,
+
+			Argv:          cfg.Argv,
+			Env:           cfg.Env,
+			Dir:           cfg.Dir,
+			InheritCaller: cfg.InheritCaller,
+		}
+	}
+
+	// scratchpads can be started where they were called from
+	// (working directory might be gone - the server's one is used then).
+	// Named definitions are only known to the server, which drops the
+	// caller's context unless the definition inherits it.
+	if !cfg.Inline || cfg.InheritCaller {
+		msg.Caller = &scratch.Caller{
+			Env: os.Environ(),
+		}
+		dir, err := os.Getwd()
+		if err == nil {
+			msg.Caller.Dir = dir
+		}
+	}
+
+	err = socket.Invoke(socketName, msg, nil)
 	if err != nil {
 		return err
 	}
@@ -143,6 +280,73 @@ func mainCall() error {
 	return nil
 }
 
+// mainAdopt is a main function for adopt mode.
+func mainAdopt() error {
+	cfg, err := scratch.ParseAdoptFlags()
+	if err != nil {
+		return err
+	}
+
+	return socket.Invoke(socketName, &socketMessage{
+		Command: cfg.Command,
+		ID:      cfg.ID,
+		Definition: &scratch.Definition{
+			Position:     cfg.Position,
+			WindowWidth:  cfg.WindowWidth,
+			WindowHeight: cfg.WindowHeight,
+			Margin:       cfg.Margin,
+			Group:        cfg.Group,
+		},
+	}, nil)
+}
+
+// mainSubscribe is a main function for subscribe mode.
+func mainSubscribe() error {
+	return socket.InvokeStream(socketName, &socketMessage{Command: scratch.CommandSubscribe}, func(raw json.RawMessage) error {
+		_, err := fmt.Printf("%s\n", raw)
+		return err
+	})
+}
+
+// mainLogs is a main function for logs mode.
+func mainLogs() error {
+	cfg, err := scratch.ParseLogsFlags()
+	if err != nil {
+		return err
+	}
+
+	msg := &socketMessage{
+		Command: scratch.CommandLogs,
+		ID:      cfg.ID,
+		Follow:  cfg.Follow,
+	}
+	return socket.InvokeStream(socketName, msg, func(raw json.RawMessage) error {
+		var line string
+		err := json.Unmarshal(raw, &line)
+		if err != nil {
+			return fmt.Errorf("json.Unmarshal: %w", err)
+		}
+		_, err = fmt.Println(line)
+		return err
+	})
+}
+
+// mainList is a main function for list mode.
+func mainList() error {
+	cfg, err := scratch.ParseListFlags()
+	if err != nil {
+		return err
+	}
+
+	var list []*scratch.Status
+	err = socket.Invoke(socketName, &socketMessage{Command: scratch.CommandList}, &list)
+	if err != nil {
+		return err
+	}
+
+	return scratch.WriteList(os.Stdout, list, cfg.Format)
+}
+
 func main() {
 	subcmd, err := scratch.GetSubcommand()
 	if err != nil {
@@ -156,10 +360,52 @@ func main() {
 			log.Fatalf("server: %s", err)
 		}
 		return
-	case scratch.SubcommandCall:
-		err := mainCall()
+	case scratch.SubcommandCall, scratch.SubcommandToggle, scratch.SubcommandShow,
+		scratch.SubcommandHide, scratch.SubcommandSummon:
+		err := mainCall(subcmd)
+		if err != nil {
+			log.Fatalf("%s: %s", subcmd, err)
+		}
+		return
+	case scratch.SubcommandCycle, scratch.SubcommandRelease, scratch.SubcommandKill, scratch.SubcommandRestart,
+		scratch.SubcommandResetShape:
+		name, err := scratch.ParseNameArg(subcmd)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = socket.Invoke(socketName, &socketMessage{Command: subcmd.Command(), ID: name}, nil)
+		if err != nil {
+			log.Fatalf("%s: %s", subcmd, err)
+		}
+		return
+	case scratch.SubcommandAdopt:
+		err := mainAdopt()
+		if err != nil {
+			log.Fatalf("%s: %s", subcmd, err)
+		}
+		return
+	case scratch.SubcommandLogs:
+		err := mainLogs()
+		if err != nil {
+			log.Fatalf("%s: %s", subcmd, err)
+		}
+		return
+	case scratch.SubcommandList:
+		err := mainList()
+		if err != nil {
+			log.Fatalf("%s: %s", subcmd, err)
+		}
+		return
+	case scratch.SubcommandSubscribe:
+		err := mainSubscribe()
+		if err != nil {
+			log.Fatalf("%s: %s", subcmd, err)
+		}
+		return
+	case scratch.SubcommandHideAll:
+		err := socket.Invoke(socketName, &socketMessage{Command: scratch.CommandHideAll}, nil)
 		if err != nil {
-			log.Fatalf("call: %s", err)
+			log.Fatalf("%s: %s", subcmd, err)
 		}
 		return
 	default:
@@ -5,8 +5,10 @@ import (
 	"flag"
 	"fmt"
 	"os"
-	"strconv"
+	"sort"
 	"strings"
+
+	"github.com/kndndrj/sway-scripts/internal/core"
 )
 
 type Subcommand int
@@ -15,6 +17,20 @@ const (
 	SubcommandUnknown Subcommand = iota
 	SubcommandServe
 	SubcommandCall
+	SubcommandToggle
+	SubcommandShow
+	SubcommandHide
+	SubcommandSummon
+	SubcommandHideAll
+	SubcommandCycle
+	SubcommandList
+	SubcommandSubscribe
+	SubcommandAdopt
+	SubcommandRelease
+	SubcommandKill
+	SubcommandRestart
+	SubcommandLogs
+	SubcommandResetShape
 )
 
 func SubcommandFromString(s string) Subcommand {
@@ -23,6 +39,34 @@ func SubcommandFromString(s string) Subcommand {
 		return SubcommandServe
 	case "call":
 		return SubcommandCall
+	case "toggle":
+		return SubcommandToggle
+	case "show":
+		return SubcommandShow
+	case "hide":
+		return SubcommandHide
+	case "summon":
+		return SubcommandSummon
+	case "hide-all":
+		return SubcommandHideAll
+	case "cycle":
+		return SubcommandCycle
+	case "list":
+		return SubcommandList
+	case "subscribe":
+		return SubcommandSubscribe
+	case "adopt":
+		return SubcommandAdopt
+	case "release":
+		return SubcommandRelease
+	case "kill":
+		return SubcommandKill
+	case "restart":
+		return SubcommandRestart
+	case "logs":
+		return SubcommandLogs
+	case "reset-shape":
+		return SubcommandResetShape
 	}
 	return SubcommandUnknown
 }
@@ -33,10 +77,72 @@ func (s Subcommand) String() string {
 		return "serve"
 	case SubcommandCall:
 		return "call"
+	case SubcommandToggle:
+		return "toggle"
+	case SubcommandShow:
+		return "show"
+	case SubcommandHide:
+		return "hide"
+	case SubcommandSummon:
+		return "summon"
+	case SubcommandHideAll:
+		return "hide-all"
+	case SubcommandCycle:
+		return "cycle"
+	case SubcommandList:
+		return "list"
+	case SubcommandSubscribe:
+		return "subscribe"
+	case SubcommandAdopt:
+		return "adopt"
+	case SubcommandRelease:
+		return "release"
+	case SubcommandKill:
+		return "kill"
+	case SubcommandRestart:
+		return "restart"
+	case SubcommandLogs:
+		return "logs"
+	case SubcommandResetShape:
+		return "reset-shape"
 	}
 	return "unknown"
 }
 
+// Command returns the server command run by the subcommand.
+func (s Subcommand) Command() Command {
+	switch s {
+	case SubcommandShow:
+		return CommandShow
+	case SubcommandHide:
+		return CommandHide
+	case SubcommandSummon:
+		return CommandSummon
+	case SubcommandHideAll:
+		return CommandHideAll
+	case SubcommandCycle:
+		return CommandCycle
+	case SubcommandList:
+		return CommandList
+	case SubcommandSubscribe:
+		return CommandSubscribe
+	case SubcommandAdopt:
+		return CommandAdopt
+	case SubcommandRelease:
+		return CommandRelease
+	case SubcommandKill:
+		return CommandKill
+	case SubcommandRestart:
+		return CommandRestart
+	case SubcommandLogs:
+		return CommandLogs
+	case SubcommandResetShape:
+		return CommandResetShape
+	}
+	// "call" is an alias for "toggle"
+	return CommandToggle
+}
+
 func GetSubcommand() (Subcommand, error) {
 	if len(os.Args) < 2 {
 		return 0, errors.New("expected a subcommand")
@@ -50,93 +156,372 @@ func GetSubcommand() (Subcommand, error) {
 	return subcommand, nil
 }
 
+type ServeConfig struct {
+	// Path to the scratchpad definitions file.
+	DefinitionsPath string
+	// Definitions file was passed explicitly, so it has to exist.
+	DefinitionsRequired bool
+}
+
+func ParseServeFlags() (*ServeConfig, error) {
+	subcmd := flag.NewFlagSet(SubcommandServe.String(), flag.ExitOnError)
+	configFlag := subcmd.String("config", core.ConfigPath("sway-scratch"), "Path to the scratchpad definitions file.")
+
+	err := subcmd.Parse(os.Args[2:])
+	if err != nil {
+		return nil, err
+	}
+
+	required := false
+	subcmd.Visit(func(f *flag.Flag) {
+		if f.Name == "config" {
+			required = true
+		}
+	})
+
+	return &ServeConfig{
+		DefinitionsPath:     *configFlag,
+		DefinitionsRequired: required,
+	}, nil
+}
+
+var (
+	defaultWindowWidth  = Length{Value: 200, Unit: UnitMillimeter}
+	defaultWindowHeight = Length{Value: 100, Unit: UnitMillimeter}
+)
+
 type CallConfig struct {
-	ID           string
-	Position     Position
-	Cmd          string
-	WindowWidth  int
-	WindowHeight int
+	Command Command
+	ID      string
+	// Inline is true if the scratchpad is defined with flags.
+	// Otherwise ID is a name from the definitions file (or a command if it's not defined).
+	Inline        bool
+	Position      Position
+	Cmd           string
+	WindowWidth   Length
+	WindowHeight  Length
+	Margin        int
+	Criteria      *Criteria
+	ClaimExisting bool
+	Group         string
+	Respawn       RespawnPolicy
+	CloseOnHide   bool
+	// Argv is set instead of Cmd if the command is executed without a shell.
+	Argv          []string
+	Env           map[string]string
+	Dir           string
+	InheritCaller bool
 }
 
-func ParseCallFlags() (*CallConfig, error) {
-	// third argument is a window command
+// ParseCallFlags parses flags of subcommands that address a single scratchpad (call, toggle, show, hide, summon).
+func ParseCallFlags(sub Subcommand) (*CallConfig, error) {
+	// third argument is a scratchpad name or a window command
 	if len(os.Args) < 3 || os.Args[2][0] == '-' {
-		return nil, errors.New("expected a window command - example: kitty")
+		return nil, errors.New("expected a scratchpad name or window command - example: kitty")
 	}
 
 	cmd := os.Args[2]
 
 	const placeholderID = "<cmd>_<position>_<window_size>"
 
-	subcmd := flag.NewFlagSet(SubcommandCall.String(), flag.ExitOnError)
+	subcmd := flag.NewFlagSet(sub.String(), flag.ExitOnError)
 	idFlag := subcmd.String("id", placeholderID, "Unique id to be used by the scratchpad.")
-	positionFlag := subcmd.String("position", "center", "Position of scratchpad. Valid are: left, right, center.")
-	windowSizeFlag := subcmd.String("window_size", "200x100", "Preffered window size. <width>x<height> in [mm].")
+	placement := addPlacementFlags(subcmd)
+	respawnFlag := subcmd.String("respawn", RespawnNever.String(),
+		"Start the process again after it exits. Valid are: never, on-failure, always.")
+	closeOnHideFlag := subcmd.Bool("close_on_hide", false, "Kill the scratchpad instead of hiding it.")
+	argvFlag := subcmd.Bool("argv", false,
+		"Execute the command without a shell. Arguments of the command follow \"--\" - example: foot -argv -- -e nvim.")
+	dirFlag := subcmd.String("dir", "", "Working directory of the command (\"~\" is expanded).")
+	inheritCallerFlag := subcmd.Bool("inherit_caller", false,
+		"Start the command in the working directory and environment of the caller.")
+	env := envFlag{}
+	subcmd.Var(env, "env", "Extra environment variable of the command in KEY=VALUE form. Can be repeated.")
+
+	var crit Criteria
+	subcmd.StringVar(&crit.AppID, "app_id", "", "Match the window by app_id instead of the spawned pid.")
+	subcmd.StringVar(&crit.Class, "class", "", "Match the window by X11 class instead of the spawned pid.")
+	subcmd.StringVar(&crit.Instance, "instance", "", "Match the window by X11 instance instead of the spawned pid.")
+	subcmd.StringVar(&crit.Title, "title", "", "Match the window by title regex instead of the spawned pid.")
+	subcmd.StringVar(&crit.Mark, "mark", "", "Match the window by mark instead of the spawned pid.")
+	claimExistingFlag := subcmd.Bool("claim_existing", false,
+		"Take over an already open window matching the criteria instead of spawning a new one.")
 
 	err := subcmd.Parse(os.Args[3:])
 	if err != nil {
 		return nil, err
 	}
 
-	width, height, err := parseWindowSize(*windowSizeFlag)
+	// without any flags, scratchpad is looked up by name in the definitions file
+	// (the server runs it as a command if it's not defined)
+	inline := false
+	subcmd.Visit(func(*flag.Flag) {
+		inline = true
+	})
+	if !inline {
+		return &CallConfig{
+			Command: sub.Command(),
+			ID:      cmd,
+		}, nil
+	}
+
+	cfg := &CallConfig{
+		Command:       sub.Command(),
+		Inline:        true,
+		Cmd:           cmd,
+		CloseOnHide:   *closeOnHideFlag,
+		Dir:           *dirFlag,
+		InheritCaller: *inheritCallerFlag,
+	}
+	if len(env) > 0 {
+		cfg.Env = env
+	}
+
+	if *argvFlag {
+		cfg.Argv = append([]string{cmd}, subcmd.Args()...)
+		cfg.Cmd = ""
+	} else if subcmd.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %q - use -argv to pass arguments to the command", subcmd.Args())
+	}
+
+	err = placement.apply(cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	pos, err := parsePosition(*positionFlag)
+	cfg.Respawn, err = parseRespawnPolicy(*respawnFlag)
 	if err != nil {
 		return nil, err
 	}
 
-	id := *idFlag
+	cfg.ID = *idFlag
 	if *idFlag == "" || *idFlag == placeholderID {
-		id = fmt.Sprintf("%s_%d_%dx%d", cmd, pos, width, height)
+		name := cmd
+		if cfg.Argv != nil {
+			name = strings.Join(cfg.Argv, " ")
+		}
+		cfg.ID = fmt.Sprintf("%s_%d_%sx%s", name, cfg.Position, cfg.WindowWidth, cfg.WindowHeight)
+	}
+
+	if crit != (Criteria{}) {
+		cfg.Criteria = &crit
+		cfg.ClaimExisting = *claimExistingFlag
+	}
+
+	return cfg, nil
+}
+
+// envFlag collects repeated KEY=VALUE flags.
+type envFlag map[string]string
+
+func (e envFlag) String() string {
+	keys := make([]string, 0, len(e))
+	for key := range e {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+e[key])
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (e envFlag) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("invalid environment variable: %q, should be: KEY=VALUE", value)
+	}
+	e[key] = val
+	return nil
+}
+
+// ParseNameArg returns the only argument of subcommands that take a scratchpad id or group name
+// (cycle, release, kill, restart, reset-shape).
+func ParseNameArg(sub Subcommand) (string, error) {
+	if len(os.Args) != 3 || os.Args[2] == "" || os.Args[2][0] == '-' {
+		if sub == SubcommandCycle {
+			return "", errors.New("expected a group name - example: sway-scratch cycle panels")
+		}
+		return "", fmt.Errorf("expected a scratchpad id - example: sway-scratch %s term", sub)
+	}
+	return os.Args[2], nil
+}
+
+// ParseAdoptFlags parses arguments of the "adopt" subcommand.
+func ParseAdoptFlags() (*CallConfig, error) {
+	if len(os.Args) < 3 || os.Args[2] == "" || os.Args[2][0] == '-' {
+		return nil, errors.New("expected a scratchpad id - example: sway-scratch adopt term")
+	}
+	id := os.Args[2]
+
+	subcmd := flag.NewFlagSet(SubcommandAdopt.String(), flag.ExitOnError)
+	placement := addPlacementFlags(subcmd)
+
+	err := subcmd.Parse(os.Args[3:])
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &CallConfig{
+		Command: CommandAdopt,
+		ID:      id,
+		Inline:  true,
+	}
+
+	err = placement.apply(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// placementFlags describe where the scratchpad window is placed.
+type placementFlags struct {
+	position   *string
+	margin     *int
+	windowSize *string
+	group      *string
+}
+
+func addPlacementFlags(subcmd *flag.FlagSet) *placementFlags {
+	return &placementFlags{
+		position: subcmd.String("position", "center", "Position of scratchpad. Valid are: center, left, right, "+
+			"top, bottom, top-left, top-right, bottom-left, bottom-right, dropdown."),
+		margin: subcmd.Int("margin", 0, "Margin from the output edges in [mm] for positions anchored to an edge."),
+		windowSize: subcmd.String("window_size",
+			fmt.Sprintf("%sx%s", defaultWindowWidth, defaultWindowHeight),
+			"Preffered window size. <width>x<height>, each in [mm] (default), [px] or [%] - example: 80%x400px."),
+		group: subcmd.String("group", "", "Group of mutually exclusive scratchpads - showing one hides the others."),
+	}
+}
+
+// apply parses the flags into the config.
+func (f *placementFlags) apply(cfg *CallConfig) error {
+	var err error
+
+	cfg.WindowWidth, cfg.WindowHeight, err = parseWindowSize(*f.windowSize)
+	if err != nil {
+		return err
+	}
+
+	cfg.Position, err = parsePosition(*f.position)
+	if err != nil {
+		return err
+	}
+
+	cfg.Margin = *f.margin
+	cfg.Group = *f.group
+
+	return nil
+}
+
+type LogsConfig struct {
+	ID string
+	// Follow keeps printing new lines.
+	Follow bool
+}
+
+// ParseLogsFlags parses arguments of the "logs" subcommand.
+func ParseLogsFlags() (*LogsConfig, error) {
+	if len(os.Args) < 3 || os.Args[2] == "" || os.Args[2][0] == '-' {
+		return nil, errors.New("expected a scratchpad id - example: sway-scratch logs term -f")
+	}
+	id := os.Args[2]
+
+	subcmd := flag.NewFlagSet(SubcommandLogs.String(), flag.ExitOnError)
+	followFlag := subcmd.Bool("f", false, "Keep printing new lines as they are written.")
+
+	err := subcmd.Parse(os.Args[3:])
+	if err != nil {
+		return nil, err
 	}
 
-	return &CallConfig{
-		ID:           id,
-		Position:     pos,
-		Cmd:          cmd,
-		WindowWidth:  width,
-		WindowHeight: height,
+	return &LogsConfig{
+		ID:     id,
+		Follow: *followFlag,
+	}, nil
+}
+
+// Format is the output format of the "list" subcommand.
+type Format string
+
+const (
+	FormatText Format = "text"
+	FormatJSON Format = "json"
+)
+
+type ListConfig struct {
+	Format Format
+}
+
+func ParseListFlags() (*ListConfig, error) {
+	subcmd := flag.NewFlagSet(SubcommandList.String(), flag.ExitOnError)
+	formatFlag := subcmd.String("format", string(FormatText), "Output format. Valid are: text, json.")
+
+	err := subcmd.Parse(os.Args[2:])
+	if err != nil {
+		return nil, err
+	}
+
+	format := Format(strings.ToLower(*formatFlag))
+	if format != FormatText && format != FormatJSON {
+		return nil, fmt.Errorf("invalid format: %q - should be text or json", *formatFlag)
+	}
+
+	return &ListConfig{
+		Format: format,
 	}, nil
 }
 
 func parsePosition(in string) (Position, error) {
 	input := strings.ToLower(in)
 
-	switch input {
-	case "center":
-		return PositionCenter, nil
-	case "left":
-		return PositionLeft, nil
-	case "right":
-		return PositionRight, nil
+	for pos := PositionCenter; pos <= PositionDropdown; pos++ {
+		if input == pos.String() {
+			return pos, nil
+		}
 	}
 
 	return 0, fmt.Errorf("invalid position flag: %q", in)
 }
 
-func parseWindowSize(in string) (w, h int, err error) {
-	input := strings.ToLower(in)
+func (p *Position) UnmarshalText(text []byte) error {
+	pos, err := parsePosition(string(text))
+	if err != nil {
+		return err
+	}
+	*p = pos
+	return nil
+}
 
-	sp := strings.Split(input, "x")
-	if len(sp) != 2 {
-		return 0, 0, fmt.Errorf("invlid window size format: %q, should be: <widhth>x<height>", in)
+// splitWindowSize splits "<width>x<height>" on the separator, skipping the "x" in "px" suffixes.
+func splitWindowSize(in string) []string {
+	for i := 0; i < len(in); i++ {
+		if in[i] != 'x' || (i > 0 && in[i-1] == 'p') {
+			continue
+		}
+		return []string{in[:i], in[i+1:]}
 	}
+	return []string{in}
+}
 
-	w, err = strconv.Atoi(sp[0])
-	if err != nil {
-		return 0, 0, fmt.Errorf("invalid width parameter: %q - not a number", sp[0])
+func parseWindowSize(in string) (w, h Length, err error) {
+	sp := splitWindowSize(strings.ToLower(in))
+	if len(sp) != 2 {
+		return Length{}, Length{}, fmt.Errorf("invlid window size format: %q, should be: <widhth>x<height>", in)
 	}
 
-	h, err = strconv.Atoi(sp[1])
+	w, err = parseLength(sp[0])
 	if err != nil {
-		return 0, 0, fmt.Errorf("invalid height parameter: %q - not a number", sp[1])
+		return Length{}, Length{}, fmt.Errorf("invalid width parameter: %w", err)
 	}
 
-	if w < 1 && h < 1 {
-		return 0, 0, fmt.Errorf("invalid window size parameter: %q - widht and height should be positive integers", in)
+	h, err = parseLength(sp[1])
+	if err != nil {
+		return Length{}, Length{}, fmt.Errorf("invalid height parameter: %w", err)
 	}
 
 	return w, h, nil
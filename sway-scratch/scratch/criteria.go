@@ -0,0 +1,75 @@
+package scratch
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+
+	"github.com/joshuarubin/go-sway"
+)
+
+// Criteria selects the scratchpad window by its properties instead of the pid
+// of the spawned process. Empty fields are ignored, Title is a regular expression,
+// other fields have to match exactly.
+type Criteria struct {
+	AppID    string
+	Class    string
+	Instance string
+	Title    string
+	Mark     string
+
+	// compiled Title (set by Validate)
+	title *regexp.Regexp
+}
+
+// Validate checks the criteria and compiles the title regex. It has to be called before Matches.
+func (c *Criteria) Validate() error {
+	if c.AppID == "" && c.Class == "" && c.Instance == "" && c.Title == "" && c.Mark == "" {
+		return fmt.Errorf("empty criteria")
+	}
+	c.title = nil
+	if c.Title != "" {
+		re, err := regexp.Compile(c.Title)
+		if err != nil {
+			return fmt.Errorf("invalid title regex: %w", err)
+		}
+		c.title = re
+	}
+	return nil
+}
+
+// Matches reports whether the node satisfies all criteria.
+// Title never matches if the criteria weren't validated.
+func (c *Criteria) Matches(node *sway.Node) bool {
+	if node == nil {
+		return false
+	}
+
+	var props sway.WindowProperties
+	if node.WindowProperties != nil {
+		props = *node.WindowProperties
+	}
+
+	if c.AppID != "" && (node.AppID == nil || *node.AppID != c.AppID) {
+		return false
+	}
+	if c.Class != "" && props.Class != c.Class {
+		return false
+	}
+	if c.Instance != "" && props.Instance != c.Instance {
+		return false
+	}
+	if c.Mark != "" && !slices.Contains(node.Marks, c.Mark) {
+		return false
+	}
+	if c.Title != "" && (c.title == nil || !c.title.MatchString(node.Name)) {
+		return false
+	}
+
+	return true
+}
+
+// isWindow reports if the node is a view (tiling or floating).
+func isWindow(node *sway.Node) bool {
+	return (node.Type == sway.NodeCon || node.Type == sway.NodeFloatingCon) && node.PID != nil
+}
@@ -0,0 +1,78 @@
+package scratch
+
+import (
+	"testing"
+
+	"github.com/joshuarubin/go-sway"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCriteria_Matches(t *testing.T) {
+	appID := "org.wezfurlong.wezterm"
+	pid := uint32(1000)
+
+	wayland := &sway.Node{
+		ID:    10,
+		Type:  sway.NodeCon,
+		Name:  "nvim ~/notes",
+		PID:   &pid,
+		AppID: &appID,
+		Marks: []string{"notes"},
+	}
+	xwayland := &sway.Node{
+		ID:   11,
+		Type: sway.NodeFloatingCon,
+		Name: "Spotify Premium",
+		PID:  &pid,
+		WindowProperties: &sway.WindowProperties{
+			Class:    "Spotify",
+			Instance: "spotify",
+		},
+	}
+
+	testCases := []struct {
+		comment  string
+		criteria Criteria
+		node     *sway.Node
+		expected bool
+	}{
+		{comment: "app_id", criteria: Criteria{AppID: appID}, node: wayland, expected: true},
+		{comment: "app_id mismatch", criteria: Criteria{AppID: "kitty"}, node: wayland, expected: false},
+		{comment: "app_id of x11 window", criteria: Criteria{AppID: appID}, node: xwayland, expected: false},
+		{comment: "class", criteria: Criteria{Class: "Spotify"}, node: xwayland, expected: true},
+		{comment: "class is exact", criteria: Criteria{Class: "spotify"}, node: xwayland, expected: false},
+		{comment: "class of wayland window", criteria: Criteria{Class: "Spotify"}, node: wayland, expected: false},
+		{comment: "instance", criteria: Criteria{Instance: "spotify"}, node: xwayland, expected: true},
+		{comment: "instance mismatch", criteria: Criteria{Instance: "Spotify"}, node: xwayland, expected: false},
+		{comment: "mark", criteria: Criteria{Mark: "notes"}, node: wayland, expected: true},
+		{comment: "mark mismatch", criteria: Criteria{Mark: "note"}, node: wayland, expected: false},
+		{comment: "title regex", criteria: Criteria{Title: `^nvim .*notes$`}, node: wayland, expected: true},
+		{comment: "title regex matches substring", criteria: Criteria{Title: "Premium"}, node: xwayland, expected: true},
+		{comment: "title regex mismatch", criteria: Criteria{Title: `^Premium`}, node: xwayland, expected: false},
+		{comment: "all fields", criteria: Criteria{Class: "Spotify", Instance: "spotify", Title: "Spotify"}, node: xwayland, expected: true},
+		{comment: "one field mismatches", criteria: Criteria{AppID: appID, Mark: "todo"}, node: wayland, expected: false},
+		{comment: "no node", criteria: Criteria{AppID: appID}, node: nil, expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.comment, func(t *testing.T) {
+			require.NoError(t, tc.criteria.Validate())
+			require.Equal(t, tc.expected, tc.criteria.Matches(tc.node))
+		})
+	}
+}
+
+func TestCriteria_Validate(t *testing.T) {
+	r := require.New(t)
+
+	r.Error((&Criteria{}).Validate())
+	r.Error((&Criteria{Title: "(unclosed"}).Validate())
+
+	// title is compiled once
+	c := &Criteria{Title: "^term$"}
+	r.NoError(c.Validate())
+	r.NotNil(c.title)
+
+	// not validated criteria don't match by title
+	r.False((&Criteria{Title: "term"}).Matches(&sway.Node{Name: "term"}))
+}
@@ -0,0 +1,154 @@
+package scratch
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/kndndrj/sway-scripts/internal/core"
+)
+
+// windowSize is a window size value in the definitions file: "<width>x<height>".
+type windowSize struct {
+	width  Length
+	height Length
+}
+
+func (s *windowSize) UnmarshalText(text []byte) error {
+	w, h, err := parseWindowSize(string(text))
+	if err != nil {
+		return err
+	}
+	s.width, s.height = w, h
+	return nil
+}
+
+// fileScratchpad is a single scratchpad in the definitions file.
+type fileScratchpad struct {
+	Cmd         string         `toml:"cmd"`
+	Argv        []string       `toml:"argv"`
+	Position    *Position      `toml:"position"`
+	Size        *windowSize    `toml:"size"`
+	Margin      int            `toml:"margin"`
+	Group       string         `toml:"group"`
+	Respawn     *RespawnPolicy `toml:"respawn"`
+	Prestart    bool           `toml:"prestart"`
+	CloseOnHide bool           `toml:"close_on_hide"`
+
+	// process environment
+	Env           map[string]string `toml:"env"`
+	Dir           string            `toml:"dir"`
+	InheritCaller bool              `toml:"inherit_caller"`
+
+	// window criteria
+	ClaimExisting bool   `toml:"claim_existing"`
+	AppID         string `toml:"app_id"`
+	Class         string `toml:"class"`
+	Instance      string `toml:"instance"`
+	Title         string `toml:"title"`
+	Mark          string `toml:"mark"`
+}
+
+// definitionsFile is the layout of the definitions file.
+type definitionsFile struct {
+	Scratchpads map[string]*fileScratchpad `toml:"scratchpads"`
+}
+
+func (f *fileScratchpad) toDefinition() *Definition {
+	def := &Definition{
+		Cmd:          f.Cmd,
+		Argv:         f.Argv,
+		Position:     PositionCenter,
+		WindowWidth:  defaultWindowWidth,
+		WindowHeight: defaultWindowHeight,
+		Margin:       f.Margin,
+		Group:        f.Group,
+		Prestart:     f.Prestart,
+		CloseOnHide:  f.CloseOnHide,
+
+		Env:           f.Env,
+		Dir:           f.Dir,
+		InheritCaller: f.InheritCaller,
+	}
+
+	if f.Position != nil {
+		def.Position = *f.Position
+	}
+	if f.Respawn != nil {
+		def.Respawn = *f.Respawn
+	}
+	if f.Size != nil {
+		def.WindowWidth = f.Size.width
+		def.WindowHeight = f.Size.height
+	}
+
+	crit := Criteria{
+		AppID:    f.AppID,
+		Class:    f.Class,
+		Instance: f.Instance,
+		Title:    f.Title,
+		Mark:     f.Mark,
+	}
+	if crit != (Criteria{}) {
+		def.Criteria = &crit
+		def.ClaimExisting = f.ClaimExisting
+	}
+
+	return def
+}
+
+// commandDefinition returns the definition of a scratchpad called by a name that is not defined:
+// the name is run by the shell and the window gets the default placement.
+func commandDefinition(cmd string) *Definition {
+	return &Definition{
+		Cmd:          cmd,
+		Position:     PositionCenter,
+		WindowWidth:  defaultWindowWidth,
+		WindowHeight: defaultWindowHeight,
+	}
+}
+
+// LoadDefinitions reads named scratchpad definitions from the file.
+// If the file doesn't exist and is not required, no definitions are returned.
+func LoadDefinitions(path string, required bool) (map[string]*Definition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && !required {
+			return make(map[string]*Definition), nil
+		}
+		return nil, fmt.Errorf("os.ReadFile: %w", err)
+	}
+
+	return parseDefinitions(path, data)
+}
+
+func parseDefinitions(path string, data []byte) (map[string]*Definition, error) {
+	var f definitionsFile
+	err := core.DecodeTOML(path, data, &f)
+	if err != nil {
+		return nil, err
+	}
+
+	// sort names for deterministic errors
+	names := make([]string, 0, len(f.Scratchpads))
+	for name := range f.Scratchpads {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	defs := make(map[string]*Definition, len(f.Scratchpads))
+	for _, name := range names {
+		def := f.Scratchpads[name].toDefinition()
+		err := def.Validate()
+		if err != nil {
+			if line := core.TableLine(data, "scratchpads", name); line > 0 {
+				return nil, fmt.Errorf("%s:%d: scratchpads.%s: %w", path, line, name, err)
+			}
+			return nil, fmt.Errorf("%s: scratchpads.%s: %w", path, name, err)
+		}
+		defs[name] = def
+	}
+
+	return defs, nil
+}
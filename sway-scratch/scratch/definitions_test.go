@@ -0,0 +1,154 @@
+package scratch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDefinitions(t *testing.T) {
+	r := require.New(t)
+
+	defs, err := parseDefinitions("definitions", []byte(`
+[scratchpads.term]
+cmd = "kitty --class scratch-term"
+position = "dropdown"
+size = "100%x40%"
+margin = 5
+group = "panels"
+
+[scratchpads.notes]
+argv = ["obsidian"]
+size = "800pxx600px"
+respawn = "on-failure"
+prestart = true
+app_id = "obsidian"
+claim_existing = true
+
+[scratchpads."music player"]
+cmd = "spotify"
+title = "^Spotify"
+`))
+	r.NoError(err)
+	r.Len(defs, 3)
+
+	term := defs["term"]
+	r.Equal("kitty --class scratch-term", term.Cmd)
+	r.Equal(PositionDropdown, term.Position)
+	r.Equal(Length{Value: 100, Unit: UnitPercent}, term.WindowWidth)
+	r.Equal(Length{Value: 40, Unit: UnitPercent}, term.WindowHeight)
+	r.Equal(5, term.Margin)
+	r.Equal("panels", term.Group)
+	r.Nil(term.Criteria)
+
+	notes := defs["notes"]
+	r.Equal([]string{"obsidian"}, notes.Argv)
+	r.Equal(Length{Value: 800, Unit: UnitPixel}, notes.WindowWidth)
+	r.Equal(Length{Value: 600, Unit: UnitPixel}, notes.WindowHeight)
+	r.Equal(RespawnOnFailure, notes.Respawn)
+	r.True(notes.Prestart)
+	r.Equal("obsidian", notes.Criteria.AppID)
+	r.True(notes.ClaimExisting)
+
+	// defaults
+	music := defs["music player"]
+	r.Equal(PositionCenter, music.Position)
+	r.Equal(defaultWindowWidth, music.WindowWidth)
+	r.Equal(defaultWindowHeight, music.WindowHeight)
+	r.Equal(RespawnNever, music.Respawn)
+	r.Equal("^Spotify", music.Criteria.Title)
+	r.False(music.ClaimExisting)
+}
+
+func TestParseDefinitions_Errors(t *testing.T) {
+	testCases := []struct {
+		comment  string
+		contents string
+		expected string
+	}{
+		{
+			comment:  "syntax error",
+			contents: "[scratchpads.term]\ncmd = \n",
+			expected: "definitions:2:",
+		},
+		{
+			comment:  "unknown key",
+			contents: "[scratchpads.term]\ncmd = \"kitty\"\npostion = \"left\"\n",
+			expected: "definitions:3: unknown key: \"scratchpads.term.postion\"",
+		},
+		{
+			comment:  "unknown top level key",
+			contents: "\nscratchpad = 1\n",
+			expected: "definitions:2: unknown key: \"scratchpad\"",
+		},
+		{
+			comment:  "invalid window size",
+			contents: "[scratchpads.term]\ncmd = \"kitty\"\n\nsize = \"400\"\n",
+			expected: "definitions:4: scratchpads.term.size: invlid window size format",
+		},
+		{
+			comment:  "invalid length",
+			contents: "[scratchpads.term]\ncmd = \"kitty\"\nsize = \"wide x 40%\"\n",
+			expected: "definitions:3: scratchpads.term.size: invalid width parameter",
+		},
+		{
+			comment:  "invalid position",
+			contents: "[scratchpads.term]\ncmd = \"kitty\"\nposition = \"middle\"\n",
+			expected: "definitions:3: scratchpads.term.position: invalid position flag",
+		},
+		{
+			comment:  "invalid respawn policy",
+			contents: "[scratchpads.term]\ncmd = \"kitty\"\nrespawn = \"sometimes\"\n",
+			expected: "definitions:3: scratchpads.term.respawn: invalid respawn policy",
+		},
+		{
+			comment:  "no command",
+			contents: "[scratchpads.term]\ncmd = \"kitty\"\n\n[scratchpads.notes]\nposition = \"left\"\n",
+			expected: "definitions:4: scratchpads.notes: no command provided",
+		},
+		{
+			comment:  "cmd and argv",
+			contents: "\n[scratchpads.\"my term\"]\ncmd = \"kitty\"\nargv = [\"kitty\"]\n",
+			expected: "definitions:2: scratchpads.my term: cmd and argv are mutually exclusive",
+		},
+		{
+			comment:  "negative margin in an inline table",
+			contents: "[scratchpads]\nterm = { cmd = \"kitty\", margin = -1 }\n",
+			expected: "definitions:2: scratchpads.term: invalid margin",
+		},
+		{
+			comment:  "invalid title regex",
+			contents: "[scratchpads.term]\ncmd = \"kitty\"\ntitle = \"(term\"\n",
+			expected: "definitions:1: scratchpads.term: invalid criteria",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.comment, func(t *testing.T) {
+			_, err := parseDefinitions("definitions", []byte(tc.contents))
+			require.ErrorContains(t, err, tc.expected)
+		})
+	}
+}
+
+func TestLoadDefinitions_Missing(t *testing.T) {
+	r := require.New(t)
+
+	path := filepath.Join(t.TempDir(), "definitions.toml")
+
+	// missing default file means no definitions
+	defs, err := LoadDefinitions(path, false)
+	r.NoError(err)
+	r.Empty(defs)
+
+	// explicitly provided file has to exist
+	_, err = LoadDefinitions(path, true)
+	r.ErrorIs(err, os.ErrNotExist)
+
+	r.NoError(os.WriteFile(path, []byte("[scratchpads.term]\ncmd = \"kitty\"\n"), 0o600))
+	defs, err = LoadDefinitions(path, true)
+	r.NoError(err)
+	r.Contains(defs, "term")
+}
@@ -0,0 +1,91 @@
+package scratch
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType describes what happened to the scratchpad.
+type EventType string
+
+const (
+	EventSpawned      EventType = "spawned"
+	EventShown        EventType = "shown"
+	EventHidden       EventType = "hidden"
+	EventExited       EventType = "exited"
+	EventRepositioned EventType = "repositioned"
+)
+
+// Event is sent to subscribers on scratchpad state changes.
+type Event struct {
+	Type  EventType `json:"event"`
+	Time  time.Time `json:"time"`
+	ID    string    `json:"id"`
+	Pid   int       `json:"pid,omitempty"`
+	ConID int64     `json:"con_id,omitempty"`
+	// Output the window is visible on.
+	Output string `json:"output,omitempty"`
+	Shape  *Shape `json:"shape,omitempty"`
+	// Exit status of the spawned process.
+	Status string `json:"status,omitempty"`
+}
+
+// subscriberBuffer is how many events can wait for a subscriber before it's dropped.
+const subscriberBuffer = 64
+
+// hub fans out events to subscribers.
+// Subscribers that don't keep up are dropped instead of blocking the server.
+type hub struct {
+	mu   sync.Mutex
+	subs map[chan *Event]struct{}
+}
+
+func newHub() *hub {
+	return &hub{
+		subs: make(map[chan *Event]struct{}),
+	}
+}
+
+// subscribe returns a channel of events and a function to cancel the subscription.
+// The channel is closed on cancel or when the subscriber is dropped.
+func (h *hub) subscribe() (<-chan *Event, func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ch := make(chan *Event, subscriberBuffer)
+	h.subs[ch] = struct{}{}
+
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.remove(ch)
+	}
+}
+
+// remove closes the subscriber channel. Caller holds the lock.
+func (h *hub) remove(ch chan *Event) {
+	if _, ok := h.subs[ch]; !ok {
+		return
+	}
+	delete(h.subs, ch)
+	close(ch)
+}
+
+// publish sends the event to all subscribers without blocking.
+func (h *hub) publish(e *Event) {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subs {
+		select {
+		case ch <- e:
+		default:
+			// slow reader
+			h.remove(ch)
+		}
+	}
+}
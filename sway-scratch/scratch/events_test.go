@@ -0,0 +1,47 @@
+package scratch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHub(t *testing.T) {
+	r := require.New(t)
+
+	h := newHub()
+
+	fast, cancelFast := h.subscribe()
+	defer cancelFast()
+	slow, cancelSlow := h.subscribe()
+	defer cancelSlow()
+
+	// fast subscriber keeps up, slow one never reads
+	for i := range subscriberBuffer + 1 {
+		h.publish(&Event{Type: EventShown, Pid: i})
+		r.Equal(i, (<-fast).Pid)
+	}
+
+	// slow subscriber is dropped after its buffer fills up
+	for range subscriberBuffer {
+		_, ok := <-slow
+		r.True(ok)
+	}
+	_, ok := <-slow
+	r.False(ok)
+
+	h.publish(&Event{Type: EventHidden})
+	r.Equal(EventHidden, (<-fast).Type)
+
+	// cancel closes the channel and can be called multiple times
+	cancelFast()
+	cancelFast()
+	_, ok = <-fast
+	r.False(ok)
+
+	// nothing to publish to
+	h.publish(&Event{Type: EventExited})
+
+	var nilHub *hub
+	nilHub.publish(&Event{Type: EventExited})
+}
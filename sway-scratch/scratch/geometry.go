@@ -0,0 +1,131 @@
+package scratch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/joshuarubin/go-sway"
+	"github.com/kndndrj/sway-scripts/internal/core"
+)
+
+// outputKey identifies the output of a shape override.
+// Outputs without make, model and serial (headless) fall back to the name.
+func outputKey(out *core.Output) string {
+	id := out.Identifier()
+	if strings.TrimSpace(id) == "" {
+		return out.Name
+	}
+	return id
+}
+
+// ShapeOn returns the shape of the window on the provided output:
+// the one set by the user or the calculated one.
+func (s *Scratchpad) ShapeOn(out *core.Output) *Shape {
+	override, ok := s.overrides[outputKey(out)]
+	if !ok {
+		return s.CalculateWindowShape(out)
+	}
+
+	return &Shape{
+		X:      out.X + override.X,
+		Y:      out.Y + override.Y,
+		Width:  override.Width,
+		Height: override.Height,
+	}
+}
+
+// NeedsReposition reports whether the shape differs from the one applied to the window.
+func (s *Scratchpad) NeedsReposition(shape *Shape) bool {
+	return !s.shapeApplied || s.shape == nil || *s.shape != *shape
+}
+
+// windowRect returns the current geometry of the tracked window.
+func (s *Scratchpad) windowRect(ctx context.Context) (*sway.Rect, error) {
+	tree, err := s.client.GetTree(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("s.client.GetTree: %w", err)
+	}
+
+	node := tree.TraverseNodes(func(n *sway.Node) bool {
+		return n.ID == s.ConID
+	})
+	if node == nil {
+		return nil, errNoMatchingNode
+	}
+
+	rect := node.Rect
+	return &rect, nil
+}
+
+// observeWindow records the current geometry of the visible window on the provided output.
+// Geometry carried by window events might be stale (events are queued and out of sync
+// with the tree), so it's read from the tree instead.
+func (s *Scratchpad) observeWindow(ctx context.Context, out *core.Output) (bool, error) {
+	if !s.shapeApplied || s.shape == nil {
+		return false, nil
+	}
+
+	rect, err := s.windowRect(ctx)
+	if err != nil {
+		if errors.Is(err, errNoMatchingNode) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return s.observe(*rect, out), nil
+}
+
+// observe records geometry of the visible window on the provided output.
+// Geometry read right after the shape was applied (or the first one seen) is the reference, any later change
+// is made by the user - the shifted shape is kept as an override for the output.
+// It reports whether the override was updated.
+func (s *Scratchpad) observe(rect sway.Rect, out *core.Output) bool {
+	if !s.shapeApplied || s.shape == nil {
+		return false
+	}
+	if s.observed == nil {
+		s.observed = &rect
+		return false
+	}
+	if rect == *s.observed {
+		return false
+	}
+
+	prev := *s.observed
+	s.observed = &rect
+
+	s.shape = &Shape{
+		X:      s.shape.X + int(rect.X-prev.X),
+		Y:      s.shape.Y + int(rect.Y-prev.Y),
+		Width:  s.shape.Width + int(rect.Width-prev.Width),
+		Height: s.shape.Height + int(rect.Height-prev.Height),
+	}
+
+	if s.overrides == nil {
+		s.overrides = make(map[string]*Shape)
+	}
+	s.overrides[outputKey(out)] = &Shape{
+		X:      s.shape.X - out.X,
+		Y:      s.shape.Y - out.Y,
+		Width:  s.shape.Width,
+		Height: s.shape.Height,
+	}
+
+	s.emit(EventRepositioned)
+	return true
+}
+
+// forgetGeometry drops the observed geometry - window might have been placed anywhere.
+func (s *Scratchpad) forgetGeometry() {
+	s.shapeApplied = false
+	s.observed = nil
+}
+
+// ResetShape drops shapes set by the user, the calculated shape is applied on the next event.
+func (s *Scratchpad) ResetShape() {
+	s.overrides = nil
+	s.forgetGeometry()
+}
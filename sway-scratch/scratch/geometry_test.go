@@ -0,0 +1,134 @@
+package scratch
+
+import (
+	"context"
+	"io"
+	"log"
+	"testing"
+
+	"github.com/joshuarubin/go-sway"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kndndrj/sway-scripts/internal/core"
+)
+
+func TestScratchpad_ShapeOverride(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	// 1 px per mm, second output on the right
+	laptop := &core.Output{
+		Name: "eDP-1", Make: "BOE", Model: "0x095F", Serial: "1",
+		Width: 1000, Height: 500, PhysicalWidth: 1000, PhysicalHeight: 500,
+	}
+	monitor := &core.Output{
+		Name: "DP-1", Make: "Dell", Model: "U2720Q", Serial: "2",
+		Width: 2000, Height: 1000, PhysicalWidth: 2000, PhysicalHeight: 1000, X: 1000,
+	}
+
+	client := &fakeClient{}
+	sc := newScratchpad(log.New(io.Discard, "", 0), client, "term", &Definition{
+		Cmd:          "kitty",
+		WindowWidth:  Length{Value: 200},
+		WindowHeight: Length{Value: 100},
+	})
+	sc.ConID = 10
+	sc.moveTo(&core.NodeLocation{Workspace: "1", Output: laptop.Name})
+
+	// window is placed with the calculated shape
+	calculated := sc.CalculateWindowShape(laptop)
+	r.Equal(calculated, sc.ShapeOn(laptop))
+	r.True(sc.NeedsReposition(calculated))
+	r.NoError(sc.Reposition(ctx, calculated))
+	r.False(sc.NeedsReposition(calculated))
+
+	// first observed geometry is the reference - it includes window decorations
+	applied := sway.Rect{X: 400, Y: 175, Width: 202, Height: 127}
+	r.False(sc.observe(applied, laptop))
+	r.False(sc.observe(applied, laptop))
+
+	// user moved and resized the window
+	moved := sway.Rect{X: 100, Y: 75, Width: 302, Height: 227}
+	r.True(sc.observe(moved, laptop))
+
+	expected := &Shape{X: 100, Y: 100, Width: 300, Height: 200}
+	r.Equal(expected, sc.shape)
+	r.Equal(expected, sc.ShapeOn(laptop))
+	r.False(sc.NeedsReposition(sc.ShapeOn(laptop)))
+
+	// override is kept only for the output it was made on
+	r.Equal(sc.CalculateWindowShape(monitor), sc.ShapeOn(monitor))
+
+	// override survives hiding and showing
+	sc.moveTo(&core.NodeLocation{Workspace: core.ScratchWorkspace, Output: "__i3"})
+	sc.moveTo(&core.NodeLocation{Workspace: "1", Output: laptop.Name})
+	r.Equal(expected, sc.ShapeOn(laptop))
+	r.True(sc.NeedsReposition(expected))
+
+	// geometry sway sets before the shape is applied is not the user's
+	r.False(sc.observe(sway.Rect{X: 10, Y: 10, Width: 202, Height: 127}, laptop))
+	r.NoError(sc.Reposition(ctx, expected))
+	r.False(sc.observe(moved, laptop))
+	r.Equal(expected, sc.ShapeOn(laptop))
+
+	// reset goes back to the calculated shape
+	sc.ResetShape()
+	r.Equal(calculated, sc.ShapeOn(laptop))
+	r.True(sc.NeedsReposition(calculated))
+}
+
+func TestScratchpad_StaleGeometry(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	laptop := &core.Output{
+		Name: "eDP-1", Width: 1000, Height: 500, PhysicalWidth: 1000, PhysicalHeight: 500,
+	}
+
+	// window is shown where sway puts it
+	shown := sway.Rect{X: 10, Y: 10, Width: 640, Height: 480}
+	window := &sway.Node{ID: 10, Type: sway.NodeFloatingCon, Rect: shown}
+	client := &fakeClient{tree: newTree(window, "1")}
+
+	sc := newScratchpad(log.New(io.Discard, "", 0), client, "term", &Definition{
+		Cmd:          "kitty",
+		WindowWidth:  Length{Value: 200},
+		WindowHeight: Length{Value: 100},
+	})
+	sc.ConID = 10
+	sc.moveTo(&core.NodeLocation{Workspace: "1", Output: laptop.Name})
+
+	// sway applies the shape by the time the commands return
+	shape := sc.ShapeOn(laptop)
+	applied := sway.Rect{X: 400, Y: 175, Width: 202, Height: 127}
+	window.Rect = applied
+	r.NoError(sc.Reposition(ctx, shape))
+	r.Equal(&applied, sc.observed)
+
+	// event queued by the show still carries the old geometry, the tree has the applied one
+	changed, err := sc.observeWindow(ctx, laptop)
+	r.NoError(err)
+	r.False(changed)
+	r.Nil(sc.overrides)
+	r.Equal(shape, sc.ShapeOn(laptop))
+
+	// user moved the window
+	window.Rect = sway.Rect{X: 100, Y: 75, Width: 202, Height: 127}
+	changed, err = sc.observeWindow(ctx, laptop)
+	r.NoError(err)
+	r.True(changed)
+	r.Equal(&Shape{X: 100, Y: 100, Width: 200, Height: 100}, sc.ShapeOn(laptop))
+
+	// closed window is not observed
+	sc.ConID = 11
+	changed, err = sc.observeWindow(ctx, laptop)
+	r.NoError(err)
+	r.False(changed)
+}
+
+func TestOutputKey(t *testing.T) {
+	r := require.New(t)
+
+	r.Equal("Dell U2720Q 2", outputKey(&core.Output{Name: "DP-1", Make: "Dell", Model: "U2720Q", Serial: "2"}))
+	r.Equal("HEADLESS-1", outputKey(&core.Output{Name: "HEADLESS-1"}))
+}
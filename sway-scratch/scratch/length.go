@@ -0,0 +1,99 @@
+package scratch
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Unit of a window dimension.
+type Unit int
+
+const (
+	UnitMillimeter Unit = iota
+	UnitPixel
+	UnitPercent
+)
+
+func (u Unit) String() string {
+	switch u {
+	case UnitMillimeter:
+		return "mm"
+	case UnitPixel:
+		return "px"
+	case UnitPercent:
+		return "%"
+	}
+	return "unknown"
+}
+
+// fallbackDPI is used to convert [mm] to [px] on outputs that don't report their physical size
+// (projectors, headless outputs, some VMs).
+const fallbackDPI = 96
+
+// Length is a single window dimension.
+type Length struct {
+	Value int
+	Unit  Unit
+}
+
+func (l Length) String() string {
+	return strconv.Itoa(l.Value) + l.Unit.String()
+}
+
+// Pixels converts the length to pixels on an output with the provided
+// pixel and physical ([mm]) dimension.
+func (l Length) Pixels(pixels, physical int) int {
+	switch l.Unit {
+	case UnitPixel:
+		return l.Value
+	case UnitPercent:
+		return (l.Value * pixels) / 100
+	}
+
+	if physical <= 0 {
+		return (l.Value * fallbackDPI * 10) / 254
+	}
+	return (l.Value * pixels) / physical
+}
+
+func (l Length) Validate() error {
+	if l.Value < 1 {
+		return fmt.Errorf("invalid length: %q - should be a positive integer", l)
+	}
+	if l.Unit == UnitPercent && l.Value > 100 {
+		return fmt.Errorf("invalid length: %q - percentage should not exceed 100", l)
+	}
+	if l.Unit < UnitMillimeter || l.Unit > UnitPercent {
+		return fmt.Errorf("invalid unit: %d", l.Unit)
+	}
+	return nil
+}
+
+// parseLength parses a number with an optional unit suffix (mm, px or %).
+// Without a suffix, value is in [mm].
+func parseLength(in string) (Length, error) {
+	input := strings.TrimSpace(strings.ToLower(in))
+
+	unit := UnitMillimeter
+	for _, u := range []Unit{UnitMillimeter, UnitPixel, UnitPercent} {
+		if strings.HasSuffix(input, u.String()) {
+			unit = u
+			input = strings.TrimSuffix(input, u.String())
+			break
+		}
+	}
+
+	v, err := strconv.Atoi(input)
+	if err != nil {
+		return Length{}, fmt.Errorf("invalid length: %q - not a number", in)
+	}
+
+	l := Length{Value: v, Unit: unit}
+	err = l.Validate()
+	if err != nil {
+		return Length{}, err
+	}
+
+	return l, nil
+}
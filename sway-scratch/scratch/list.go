@@ -0,0 +1,48 @@
+package scratch
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+)
+
+// WriteList writes the scratchpad list in the provided format.
+func WriteList(w io.Writer, list []*Status, format Format) error {
+	if format == FormatJSON {
+		err := json.NewEncoder(w).Encode(list)
+		if err != nil {
+			return fmt.Errorf("json.Encode: %w", err)
+		}
+		return nil
+	}
+
+	// missing values are replaced with a dash
+	orDash := func(s string) string {
+		if s == "" || s == "0" {
+			return "-"
+		}
+		return s
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tCMD\tPID\tCON_ID\tVISIBILITY\tOUTPUT\tSHAPE")
+	for _, st := range list {
+		shape := ""
+		if st.Shape != nil {
+			shape = st.Shape.String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			st.ID,
+			st.Cmd,
+			orDash(strconv.Itoa(st.Pid)),
+			orDash(strconv.FormatInt(st.ConID, 10)),
+			st.Visibility,
+			orDash(st.Output),
+			orDash(shape),
+		)
+	}
+
+	return tw.Flush()
+}
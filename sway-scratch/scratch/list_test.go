@@ -0,0 +1,59 @@
+package scratch
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kndndrj/sway-scripts/internal/core"
+)
+
+func TestServer_List(t *testing.T) {
+	r := require.New(t)
+
+	client := &fakeClient{}
+	srv := NewServer(log.New(io.Discard, "", 0), client, nil, nil)
+	srv.SetDefinitions(map[string]*Definition{
+		"term":  {Cmd: "kitty", WindowWidth: Length{Value: 1}, WindowHeight: Length{Value: 1}},
+		"notes": {Cmd: "obsidian", WindowWidth: Length{Value: 1}, WindowHeight: Length{Value: 1}, Group: "panels"},
+	})
+
+	term, err := srv.getScratchpad("term", nil)
+	r.NoError(err)
+	term.Pid = 1000
+	term.ConID = 11
+	term.SetLocation(&core.NodeLocation{Workspace: "1", Output: "eDP-1"})
+	term.shape = &Shape{X: 10, Y: 20, Width: 300, Height: 200}
+
+	notes, err := srv.getScratchpad("notes", nil)
+	r.NoError(err)
+	notes.Pid = 2000
+	notes.pendingUntil = time.Now().Add(time.Minute)
+
+	list := srv.List()
+	r.Equal([]*Status{
+		{ID: "notes", Cmd: "obsidian", Group: "panels", Pid: 2000, Visibility: VisibilityStarting},
+		{ID: "term", Cmd: "kitty", Pid: 1000, ConID: 11, Visibility: VisibilityVisible, Output: "eDP-1", Shape: term.shape},
+	}, list)
+
+	var buf bytes.Buffer
+	r.NoError(WriteList(&buf, list, FormatText))
+	r.Equal(""+
+		"ID     CMD       PID   CON_ID  VISIBILITY  OUTPUT  SHAPE\n"+
+		"notes  obsidian  2000  -       starting    -       -\n"+
+		"term   kitty     1000  11      visible     eDP-1   300x200+10+20\n",
+		buf.String())
+
+	// json output is decoded back by clients
+	buf.Reset()
+	r.NoError(WriteList(&buf, list, FormatJSON))
+
+	var decoded []*Status
+	r.NoError(json.Unmarshal(buf.Bytes(), &decoded))
+	r.Equal(list, decoded)
+}
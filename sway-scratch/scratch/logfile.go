@@ -0,0 +1,272 @@
+package scratch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// maxLogSize is the size at which a log file is rotated.
+const maxLogSize = 1 << 20
+
+var _ io.Writer = (*logFile)(nil)
+
+// logFile captures output of scratchpad processes.
+// Once the file grows over maxSize, it's moved to "<path>.1" (replacing the previous one)
+// and a new file is started. If the file can't be written, output goes to the fallback.
+type logFile struct {
+	mu       sync.Mutex
+	path     string
+	maxSize  int64
+	log      *log.Logger
+	fallback io.Writer
+
+	file *os.File
+	size int64
+	// last write failed - reported only once
+	failing bool
+}
+
+func newLogFile(logger *log.Logger, path string, maxSize int64) *logFile {
+	return &logFile{
+		path:     path,
+		maxSize:  maxSize,
+		log:      logger,
+		fallback: wrapLogger("command out: ", logger),
+	}
+}
+
+// Write never fails - the process would get a broken pipe otherwise.
+func (f *logFile) Write(p []byte) (n int, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	err = f.write(p)
+	if err != nil {
+		if !f.failing {
+			f.log.Printf("log file %s: %s", f.path, err)
+		}
+		f.failing = true
+		return f.fallback.Write(p)
+	}
+	f.failing = false
+
+	return len(p), nil
+}
+
+func (f *logFile) write(p []byte) error {
+	if f.file == nil {
+		err := f.open()
+		if err != nil {
+			return err
+		}
+	}
+
+	if f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		err := f.rotate()
+		if err != nil {
+			return err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("f.file.Write: %w", err)
+	}
+
+	return nil
+}
+
+func (f *logFile) open() error {
+	err := os.MkdirAll(filepath.Dir(f.path), 0o700)
+	if err != nil {
+		return fmt.Errorf("os.MkdirAll: %w", err)
+	}
+
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("os.OpenFile: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("file.Stat: %w", err)
+	}
+
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+func (f *logFile) rotate() error {
+	err := f.close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(f.path, f.path+".1")
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("os.Rename: %w", err)
+	}
+
+	return f.open()
+}
+
+func (f *logFile) close() error {
+	if f.file == nil {
+		return nil
+	}
+
+	err := f.file.Close()
+	f.file = nil
+	if err != nil {
+		return fmt.Errorf("f.file.Close: %w", err)
+	}
+	return nil
+}
+
+// Close closes the file. It's opened again on the next write.
+func (f *logFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.close()
+}
+
+// logFileName returns the name of the log file of the scratchpad with the provided id.
+func logFileName(id string) string {
+	return escapeID(id) + ".log"
+}
+
+// logPollInterval is how often a followed log file is checked for new lines.
+const logPollInterval = 250 * time.Millisecond
+
+// TailLog sends lines of the log file. If follow is set, it keeps sending new lines
+// (across rotations) until the context is cancelled. Missing file is treated as empty.
+func TailLog(ctx context.Context, path string, follow bool, send func(line string) error) error {
+	t := &logTail{
+		path: path,
+		send: send,
+	}
+	defer t.close()
+
+	for {
+		err := t.poll()
+		if err != nil {
+			return err
+		}
+		if !follow {
+			return t.flush()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(logPollInterval):
+		}
+	}
+}
+
+// logTail reads lines appended to a log file.
+type logTail struct {
+	path string
+	send func(line string) error
+
+	file   *os.File
+	offset int64
+	// incomplete last line
+	partial []byte
+}
+
+// poll sends lines written since the last call.
+func (t *logTail) poll() error {
+	if t.file == nil {
+		file, err := os.Open(t.path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return fmt.Errorf("os.Open: %w", err)
+		}
+		t.file = file
+		t.offset = 0
+	}
+
+	current, err := t.file.Stat()
+	if err != nil {
+		return fmt.Errorf("t.file.Stat: %w", err)
+	}
+	// file was truncated
+	if current.Size() < t.offset {
+		t.offset = 0
+	}
+
+	err = t.read()
+	if err != nil {
+		return err
+	}
+
+	// file was rotated - the rest of the old one was read above
+	latest, err := os.Stat(t.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("os.Stat: %w", err)
+	}
+	if latest == nil || !os.SameFile(current, latest) {
+		t.close()
+		if latest != nil {
+			return t.poll()
+		}
+	}
+
+	return nil
+}
+
+func (t *logTail) read() error {
+	data, err := io.ReadAll(io.NewSectionReader(t.file, t.offset, 1<<62))
+	if err != nil {
+		return fmt.Errorf("io.ReadAll: %w", err)
+	}
+	t.offset += int64(len(data))
+
+	data = append(t.partial, data...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		err := t.send(string(data[:i]))
+		if err != nil {
+			return err
+		}
+		data = data[i+1:]
+	}
+	t.partial = bytes.Clone(data)
+
+	return nil
+}
+
+// flush sends the incomplete last line.
+func (t *logTail) flush() error {
+	if len(t.partial) == 0 {
+		return nil
+	}
+	line := string(t.partial)
+	t.partial = nil
+	return t.send(line)
+}
+
+func (t *logTail) close() {
+	if t.file != nil {
+		_ = t.file.Close()
+		t.file = nil
+	}
+}
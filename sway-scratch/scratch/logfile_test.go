@@ -0,0 +1,150 @@
+package scratch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogFile_Rotate(t *testing.T) {
+	r := require.New(t)
+
+	path := filepath.Join(t.TempDir(), "state", "term.log")
+	f := newLogFile(log.New(io.Discard, "", 0), path, 10)
+	defer f.Close()
+
+	write := func(s string) {
+		n, err := f.Write([]byte(s))
+		r.NoError(err)
+		r.Equal(len(s), n)
+	}
+	content := func(path string) string {
+		data, err := os.ReadFile(path)
+		r.NoError(err)
+		return string(data)
+	}
+
+	write("one\n")
+	write("two\n")
+	r.Equal("one\ntwo\n", content(path))
+
+	// doesn't fit anymore
+	write("three\n")
+	r.Equal("three\n", content(path))
+	r.Equal("one\ntwo\n", content(path+".1"))
+
+	// previous rotation is replaced
+	write("four\n")
+	write("five\n")
+	r.Equal("four\nfive\n", content(path))
+	r.Equal("three\n", content(path+".1"))
+
+	// lines larger than the limit still end up in the file
+	write("a very long line\n")
+	r.Equal("a very long line\n", content(path))
+}
+
+func TestLogFile_Fallback(t *testing.T) {
+	r := require.New(t)
+
+	// parent is a file, so the log directory can't be created
+	parent := filepath.Join(t.TempDir(), "file")
+	r.NoError(os.WriteFile(parent, nil, 0o600))
+
+	f := newLogFile(log.New(io.Discard, "", 0), filepath.Join(parent, "term.log"), maxLogSize)
+	n, err := f.Write([]byte("output\n"))
+	r.NoError(err)
+	r.Equal(7, n)
+	r.True(f.failing)
+}
+
+func TestLogFileName(t *testing.T) {
+	r := require.New(t)
+
+	r.Equal("term.log", logFileName("term"))
+	// ids that used to share a file
+	r.NotEqual(logFileName("a/b"), logFileName("a_b"))
+	r.NotEqual(logFileName("a/b"), logFileName("a\x00b"))
+	r.NotContains(logFileName("../term"), "/")
+}
+
+func TestTailLog(t *testing.T) {
+	r := require.New(t)
+
+	path := filepath.Join(t.TempDir(), "term.log")
+
+	var lines []string
+	collect := func(line string) error {
+		lines = append(lines, line)
+		return nil
+	}
+
+	// missing file is empty
+	r.NoError(TailLog(context.Background(), path, false, collect))
+	r.Empty(lines)
+
+	r.NoError(os.WriteFile(path, []byte("one\ntwo\nunfinished"), 0o600))
+	r.NoError(TailLog(context.Background(), path, false, collect))
+	r.Equal([]string{"one", "two", "unfinished"}, lines)
+}
+
+func TestTailLog_Follow(t *testing.T) {
+	r := require.New(t)
+
+	path := filepath.Join(t.TempDir(), "term.log")
+	f := newLogFile(log.New(io.Discard, "", 0), path, 10)
+	defer f.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	lines := make(chan string, 16)
+	done := make(chan error, 1)
+	go func() {
+		done <- TailLog(ctx, path, true, func(line string) error {
+			lines <- line
+			return nil
+		})
+	}()
+
+	next := func() string {
+		select {
+		case line := <-lines:
+			return line
+		case <-time.After(5 * time.Second):
+			r.FailNow("no line received")
+			return ""
+		}
+	}
+
+	// file appears after following started
+	_, _ = fmt.Fprint(f, "one\n")
+	r.Equal("one", next())
+
+	// partial lines wait for the rest
+	_, _ = fmt.Fprint(f, "tw")
+	time.Sleep(2 * logPollInterval)
+	_, _ = fmt.Fprint(f, "o\n")
+	r.Equal("two", next())
+
+	// lines continue across rotation
+	_, _ = fmt.Fprint(f, "three\nfour\n")
+	r.Equal("three", next())
+	r.Equal("four", next())
+	r.FileExists(path + ".1")
+
+	cancel()
+	select {
+	case err := <-done:
+		r.NoError(err)
+	case <-time.After(5 * time.Second):
+		r.FailNow("tail didn't stop")
+	}
+}
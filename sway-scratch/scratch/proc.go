@@ -0,0 +1,98 @@
+package scratch
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// procTree reads parent links of processes from a proc filesystem.
+type procTree struct {
+	root string
+}
+
+func newProcTree(root string) *procTree {
+	return &procTree{
+		root: root,
+	}
+}
+
+// parseStatPpid returns parent pid from contents of /proc/<pid>/stat.
+// Format: "<pid> (<comm>) <state> <ppid> ...", comm can contain spaces and parentheses.
+func parseStatPpid(stat string) (int, error) {
+	end := strings.LastIndexByte(stat, ')')
+	if end < 0 {
+		return 0, fmt.Errorf("invalid stat format: %q", stat)
+	}
+
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 2 {
+		return 0, fmt.Errorf("invalid stat format: %q", stat)
+	}
+
+	ppid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return 0, fmt.Errorf("invalid parent pid: %q - not a number", fields[1])
+	}
+
+	return ppid, nil
+}
+
+// children returns a map of parent pid to its child pids.
+func (p *procTree) children() (map[int][]int, error) {
+	entries, err := os.ReadDir(p.root)
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadDir: %w", err)
+	}
+
+	ret := make(map[int][]int)
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			// not a process directory
+			continue
+		}
+
+		raw, err := os.ReadFile(filepath.Join(p.root, e.Name(), "stat"))
+		if err != nil {
+			// process exited in the meantime
+			continue
+		}
+
+		ppid, err := parseStatPpid(string(raw))
+		if err != nil {
+			continue
+		}
+
+		ret[ppid] = append(ret[ppid], pid)
+	}
+
+	return ret, nil
+}
+
+// Descendants returns the pid and pids of all of its descendants.
+func (p *procTree) Descendants(pid int) (map[int]struct{}, error) {
+	children, err := p.children()
+	if err != nil {
+		return nil, err
+	}
+
+	ret := map[int]struct{}{pid: {}}
+	queue := []int{pid}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		for _, child := range children[current] {
+			if _, ok := ret[child]; ok {
+				continue
+			}
+			ret[child] = struct{}{}
+			queue = append(queue, child)
+		}
+	}
+
+	return ret, nil
+}
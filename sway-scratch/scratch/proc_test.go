@@ -0,0 +1,60 @@
+package scratch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStatPpid(t *testing.T) {
+	r := require.New(t)
+
+	ppid, err := parseStatPpid("1002 (my (weird) app) S 1001 1000 500 0 -1")
+	r.NoError(err)
+	r.Equal(1001, ppid)
+
+	_, err = parseStatPpid("broken stat")
+	r.Error(err)
+
+	_, err = parseStatPpid("12 (sh) S")
+	r.Error(err)
+}
+
+func TestProcTree_Descendants(t *testing.T) {
+	procs := newProcTree("testdata/proc")
+
+	testCases := []struct {
+		comment  string
+		pid      int
+		expected map[int]struct{}
+	}{
+		{
+			comment:  "shell wrapper with nested launcher",
+			pid:      1000,
+			expected: map[int]struct{}{1000: {}, 1001: {}, 1002: {}, 1003: {}},
+		},
+		{
+			comment:  "siblings are not included",
+			pid:      2000,
+			expected: map[int]struct{}{2000: {}, 2001: {}},
+		},
+		{
+			comment:  "process without children",
+			pid:      3000,
+			expected: map[int]struct{}{3000: {}},
+		},
+		{
+			comment:  "process that doesn't exist",
+			pid:      9999,
+			expected: map[int]struct{}{9999: {}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.comment, func(t *testing.T) {
+			descendants, err := procs.Descendants(tc.pid)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, descendants)
+		})
+	}
+}
@@ -4,9 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log"
-	"os/exec"
+	"slices"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/joshuarubin/go-sway"
 	"github.com/kndndrj/sway-scripts/internal/core"
@@ -18,25 +21,105 @@ const (
 	PositionCenter Position = iota
 	PositionLeft
 	PositionRight
+	PositionTop
+	PositionBottom
+	PositionTopLeft
+	PositionTopRight
+	PositionBottomLeft
+	PositionBottomRight
+	// full width, anchored to the top edge
+	PositionDropdown
 )
 
+func (p Position) String() string {
+	switch p {
+	case PositionCenter:
+		return "center"
+	case PositionLeft:
+		return "left"
+	case PositionRight:
+		return "right"
+	case PositionTop:
+		return "top"
+	case PositionBottom:
+		return "bottom"
+	case PositionTopLeft:
+		return "top-left"
+	case PositionTopRight:
+		return "top-right"
+	case PositionBottomLeft:
+		return "bottom-left"
+	case PositionBottomRight:
+		return "bottom-right"
+	case PositionDropdown:
+		return "dropdown"
+	}
+	return "unknown"
+}
+
 // Definition defines the scratchpad.
 type Definition struct {
-	Position     Position
-	Cmd          string
-	WindowWidth  int
-	WindowHeight int
+	Position Position
+	// Cmd is run by the shell.
+	Cmd string
+	// Argv is executed directly, without a shell. Mutually exclusive with Cmd.
+	Argv         []string
+	WindowWidth  Length
+	WindowHeight Length
+	// Margin from the output edges in [mm]. Applies to positions anchored to an edge.
+	Margin int
+
+	// Criteria of the scratchpad window. If nil, window is matched by pid of the spawned command.
+	Criteria *Criteria
+	// ClaimExisting takes over an already open window matching Criteria instead of spawning a new one.
+	// Windows of other scratchpads are skipped.
+	ClaimExisting bool
+
+	// Group of mutually exclusive scratchpads - showing one hides the others (empty means no group).
+	Group string
+	// Respawn policy of the spawned process.
+	Respawn RespawnPolicy
+	// Prestart spawns the scratchpad hidden when the server starts.
+	Prestart bool
+	// CloseOnHide kills the scratchpad instead of hiding it.
+	CloseOnHide bool
+
+	// Env holds extra environment variables of the spawned process.
+	Env map[string]string
+	// Dir is the working directory of the spawned process ("~" is expanded).
+	Dir string
+	// InheritCaller starts the process in the working directory and environment of the caller.
+	// Dir and Env still take precedence.
+	InheritCaller bool
 }
 
 func (d *Definition) Validate() error {
-	if d.Cmd == "" {
-		return errors.New("no command provided")
+	err := d.validateCommand()
+	if err != nil {
+		return err
 	}
-	if d.Position < 0 || d.Position > PositionRight {
+	return d.validateWindow()
+}
+
+// validateWindow validates everything but the command - adopted windows don't have one.
+func (d *Definition) validateWindow() error {
+	if d.Position < 0 || d.Position > PositionDropdown {
 		return fmt.Errorf("invalid position: %d", d.Position)
 	}
-	if d.WindowWidth < 1 || d.WindowHeight < 1 {
-		return fmt.Errorf("invalid window dimensions: %d x %d", d.WindowWidth, d.WindowHeight)
+	if d.Respawn < RespawnNever || d.Respawn > RespawnAlways {
+		return fmt.Errorf("invalid respawn policy: %d", d.Respawn)
+	}
+	if d.Margin < 0 {
+		return fmt.Errorf("invalid margin: %d", d.Margin)
+	}
+	if d.WindowWidth.Validate() != nil || d.WindowHeight.Validate() != nil {
+		return fmt.Errorf("invalid window dimensions: %s x %s", d.WindowWidth, d.WindowHeight)
+	}
+	if d.Criteria != nil {
+		err := d.Criteria.Validate()
+		if err != nil {
+			return fmt.Errorf("invalid criteria: %w", err)
+		}
 	}
 
 	return nil
@@ -47,58 +130,427 @@ type Scratchpad struct {
 	client sway.Client
 	def    *Definition
 	log    *log.Logger
+	id     string
+	// subscribers of scratchpad events (nil if nobody listens)
+	events *hub
+	// sway mark that identifies the window once it's tracked
+	mark string
 
 	Pid int
+	// ConID is the id of the tracked window (0 if not tracked yet).
+	ConID int64
+	// window of the spawned process is claimed if it appears before this deadline
+	pendingUntil time.Time
+	// pids of the spawned process and its descendants
+	descendants map[int]struct{}
+	// where the tracked window is (nil if not known yet)
+	location *core.NodeLocation
+	// last shape applied to the window
+	shape *Shape
+	// shape was applied and the window wasn't moved elsewhere since
+	shapeApplied bool
+	// geometry of the window observed after the shape was applied (nil if not seen yet)
+	observed *sway.Rect
+	// shapes set by the user relative to the output, by output
+	overrides map[string]*Shape
+
+	// called when the spawned process exits
+	onExit func(pid int, err error)
+	// start time and exit error of the last spawned process
+	startedAt time.Time
+	exitErr   error
+	// number of consecutive respawns
+	restarts int
+	// window of the spawned process is claimed without showing it
+	hideOnClaim bool
+	// process group of the last spawned process - it might outlive the process itself
+	pgid int
+	// scratchpad was killed on purpose - it's not respawned
+	stopping bool
+	// start again once stopped, optionally hidden
+	restart       bool
+	restartHidden bool
+	// last caller that addressed the scratchpad (nil if unknown)
+	caller *Caller
+	// output of the spawned process (nil means the server log)
+	output io.Writer
 }
 
-func NewScratchpad(logger *log.Logger, c sway.Client, def *Definition) (*Scratchpad, error) {
+func NewScratchpad(logger *log.Logger, c sway.Client, id string, def *Definition) (*Scratchpad, error) {
 	err := def.Validate()
 	if err != nil {
 		return nil, fmt.Errorf("def.Validate: %w", err)
 	}
 
+	return newScratchpad(logger, c, id, def), nil
+}
+
+func newScratchpad(logger *log.Logger, c sway.Client, id string, def *Definition) *Scratchpad {
 	return &Scratchpad{
 		client: c,
 		def:    def,
 		log:    logger,
-	}, nil
+		id:     id,
+		mark:   scratchpadMark(id),
+	}
+}
+
+// markPrefix is the prefix of marks of all scratchpad windows.
+const markPrefix = "_scratch_"
+
+// hasScratchpadMark reports if the window is tracked by any scratchpad.
+func hasScratchpadMark(node *sway.Node) bool {
+	return slices.ContainsFunc(node.Marks, func(mark string) bool {
+		return strings.HasPrefix(mark, markPrefix)
+	})
 }
 
-func (s *Scratchpad) spawnWindow(ctx context.Context) (pid int, err error) {
-	// launch the program
-	cmd := exec.CommandContext(ctx, "sh", "-c", s.def.Cmd)
-	cmd.Stdout = wrapLogger("command out: ", s.log)
-	cmd.Stderr = wrapLogger("command err: ", s.log)
+// scratchpadMark returns a sway mark for the scratchpad with the provided id.
+func scratchpadMark(id string) string {
+	return markPrefix + escapeID(id)
+}
+
+// escapeID encodes the id with characters that need no escaping in criteria and file names.
+// Letters, digits and "-" are kept, "_" is doubled and other bytes become "_<hex>",
+// so different ids never give the same result.
+func escapeID(id string) string {
+	var b strings.Builder
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-':
+			b.WriteByte(c)
+		case c == '_':
+			b.WriteString("__")
+		default:
+			fmt.Fprintf(&b, "_%02x", c)
+		}
+	}
+	return b.String()
+}
+
+// outputWaitDelay is how long to wait for output of background processes
+// that keep the pipes open after the spawned process exits.
+const outputWaitDelay = time.Second
+
+func (s *Scratchpad) spawnWindow() (pid int, err error) {
+	// launch the program - not bound to any request context, it outlives it
+	cmd, err := s.command()
+	if err != nil {
+		return 0, err
+	}
+	if s.output != nil {
+		cmd.Stdout = s.output
+		cmd.Stderr = s.output
+	} else {
+		cmd.Stdout = wrapLogger("command out: ", s.log)
+		cmd.Stderr = wrapLogger("command err: ", s.log)
+	}
+	cmd.WaitDelay = outputWaitDelay
+	// own process group, so the whole tree can be terminated
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 	err = cmd.Start()
 	if err != nil {
 		return 0, fmt.Errorf("cmd.Start: %w", err)
 	}
+	pid = cmd.Process.Pid
+	s.pgid = pid
 
-	// set the scratchpad rule for window's pid
-	c := fmt.Sprintf(
-		`for_window [pid=%d] move scratchpad; for_window [pid=%d] scratchpad show`,
-		cmd.Process.Pid, cmd.Process.Pid,
-	)
-	_, err = s.client.RunCommand(ctx, c)
-	if err != nil {
-		return 0, fmt.Errorf("s.client.RunCommand: %w", err)
+	if s.output != nil {
+		fmt.Fprintf(s.output, "--- %s: started %q (pid %d)\n", time.Now().Format(time.DateTime), s.def.Command(), pid)
 	}
 
-	return cmd.Process.Pid, nil
+	// window is claimed once it appears
+	s.pendingUntil = time.Now().Add(claimTimeout)
+	s.descendants = nil
+	s.startedAt = time.Now()
+	s.exitErr = nil
+
+	// reap the process
+	onExit := s.onExit
+	go func() {
+		err := cmd.Wait()
+		if onExit != nil {
+			onExit(pid, err)
+		}
+	}()
+
+	return pid, nil
 }
 
 var errNoMatchingNode = errors.New("no matching node")
 
-func (s *Scratchpad) showScratchpad(ctx context.Context) error {
+// errStillStarting is returned when the scratchpad is started again before its window appears.
+var errStillStarting = errors.New("scratchpad window is still starting")
+
+// claimTimeout is how long to wait for the window of the spawned process.
+const claimTimeout = 30 * time.Second
+
+// Matches reports whether the node is the window of this scratchpad.
+// Untracked windows only match while the scratchpad is pending.
+func (s *Scratchpad) Matches(node *sway.Node, now time.Time) bool {
+	if node == nil || !isWindow(node) {
+		return false
+	}
+	if s.IsTracked() {
+		return node.ID == s.ConID || slices.Contains(node.Marks, s.mark)
+	}
+	// only a freshly spawned window is matched,
+	// otherwise unrelated windows would be taken over.
+	if !s.IsPending(now) {
+		return false
+	}
+	if s.def.Criteria != nil {
+		return s.def.Criteria.Matches(node)
+	}
 	if s.Pid == 0 {
+		return false
+	}
+
+	pid := int(*node.PID)
+	if pid == s.Pid {
+		return true
+	}
+	// window might be owned by a process started by the spawned one
+	_, ok := s.descendants[pid]
+	return ok
+}
+
+// refreshDescendants updates the set of processes that belong to the scratchpad.
+func (s *Scratchpad) refreshDescendants(procs *procTree) error {
+	if s.Pid == 0 || s.def.Criteria != nil {
+		return nil
+	}
+
+	descendants, err := procs.Descendants(s.Pid)
+	if err != nil {
+		return fmt.Errorf("procs.Descendants: %w", err)
+	}
+	s.descendants = descendants
+	return nil
+}
+
+// target returns sway criteria addressing the tracked scratchpad window.
+func (s *Scratchpad) target() string {
+	return fmt.Sprintf(`con_mark="^%s$"`, s.mark)
+}
+
+// findCriteriaWindow searches the tree for an existing window matching criteria
+// that doesn't belong to any scratchpad yet.
+func (s *Scratchpad) findCriteriaWindow(ctx context.Context) (*sway.Node, error) {
+	tree, err := s.client.GetTree(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("s.client.GetTree: %w", err)
+	}
+
+	node := tree.TraverseNodes(func(n *sway.Node) bool {
+		return isWindow(n) && !hasScratchpadMark(n) && s.def.Criteria.Matches(n)
+	})
+	if node == nil {
+		return nil, errNoMatchingNode
+	}
+	return node, nil
+}
+
+// Track puts the scratchpad mark on the window.
+// All subsequent commands address the window by this mark.
+func (s *Scratchpad) Track(ctx context.Context, node *sway.Node) error {
+	cmd := fmt.Sprintf("[con_id=%d] mark --add %s", node.ID, s.mark)
+
+	_, err := s.client.RunCommand(ctx, cmd)
+	if err != nil {
+		return fmt.Errorf("s.client.RunCommand: %w", err)
+	}
+
+	s.ConID = node.ID
+	s.location = nil
+	s.pendingUntil = time.Time{}
+	s.descendants = nil
+
+	return nil
+}
+
+// IsTracked reports if the scratchpad window is marked.
+func (s *Scratchpad) IsTracked() bool {
+	return s.ConID != 0
+}
+
+// Claim takes over the window, moves it to the scratchpad and
+// shows it on the provided workspace (if not nil).
+func (s *Scratchpad) Claim(ctx context.Context, node *sway.Node, ws *sway.Workspace) error {
+	err := s.Track(ctx, node)
+	if err != nil {
+		return err
+	}
+
+	cmd := fmt.Sprintf("[%s] move scratchpad", s.target())
+	if ws != nil {
+		cmd += fmt.Sprintf("; [%s] scratchpad show", s.target())
+	}
+
+	_, err = s.client.RunCommand(ctx, cmd)
+	if err != nil {
+		return fmt.Errorf("s.client.RunCommand: %w", err)
+	}
+
+	if ws != nil {
+		s.moveTo(&core.NodeLocation{Workspace: ws.Name, Output: ws.Output})
+	} else {
+		s.moveTo(&core.NodeLocation{Workspace: core.ScratchWorkspace})
+	}
+
+	return nil
+}
+
+// Release returns the window to tiling and removes the mark.
+// Hidden window is shown on the focused workspace first.
+func (s *Scratchpad) Release(ctx context.Context) error {
+	cmd := "[%[1]s] floating disable; [%[1]s] unmark " + s.mark
+	if s.Visibility(time.Now()) != VisibilityVisible {
+		cmd = "[%[1]s] scratchpad show; " + cmd
+	}
+
+	err := s.runCommand(ctx, cmd)
+	if err != nil {
+		return err
+	}
+
+	s.Untrack()
+	s.Pid = 0
+	return nil
+}
+
+// IsPending reports if scratchpad waits for its window to appear.
+func (s *Scratchpad) IsPending(now time.Time) bool {
+	return !s.IsTracked() && now.Before(s.pendingUntil)
+}
+
+// Visibility is the state of the scratchpad window.
+type Visibility int
+
+const (
+	// no window - scratchpad is not running
+	VisibilityNone Visibility = iota
+	// process is spawned, waiting for its window
+	VisibilityStarting
+	// window is in the scratchpad
+	VisibilityHidden
+	// window is shown on a workspace
+	VisibilityVisible
+)
+
+func (v Visibility) String() string {
+	switch v {
+	case VisibilityNone:
+		return "none"
+	case VisibilityStarting:
+		return "starting"
+	case VisibilityHidden:
+		return "hidden"
+	case VisibilityVisible:
+		return "visible"
+	}
+	return "unknown"
+}
+
+func (v Visibility) MarshalText() ([]byte, error) {
+	return []byte(v.String()), nil
+}
+
+func (v *Visibility) UnmarshalText(text []byte) error {
+	for vis := VisibilityNone; vis <= VisibilityVisible; vis++ {
+		if string(text) == vis.String() {
+			*v = vis
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid visibility: %q", text)
+}
+
+// Visibility returns the current state of the scratchpad window.
+func (s *Scratchpad) Visibility(now time.Time) Visibility {
+	if !s.IsTracked() {
+		if s.IsPending(now) {
+			return VisibilityStarting
+		}
+		return VisibilityNone
+	}
+	if s.location == nil || s.location.InScratchpad() {
+		return VisibilityHidden
+	}
+	return VisibilityVisible
+}
+
+// SetLocation updates where the tracked window is.
+func (s *Scratchpad) SetLocation(loc *core.NodeLocation) {
+	s.moveTo(loc)
+}
+
+// moveTo updates the location and emits an event if the window was shown or hidden.
+func (s *Scratchpad) moveTo(loc *core.NodeLocation) {
+	prev := s.location
+	s.location = loc
+
+	wasVisible := prev != nil && !prev.InScratchpad()
+	visible := loc != nil && !loc.InScratchpad()
+
+	switch {
+	case visible && (!wasVisible || prev.Workspace != loc.Workspace):
+		s.forgetGeometry()
+		s.emit(EventShown)
+	case !visible && wasVisible:
+		s.forgetGeometry()
+		s.emit(EventHidden)
+	}
+}
+
+// Untrack forgets the window (e.g. when it's closed).
+func (s *Scratchpad) Untrack() {
+	s.ConID = 0
+	s.location = nil
+	s.shape = nil
+	s.forgetGeometry()
+}
+
+// emit publishes an event about the scratchpad to subscribers.
+func (s *Scratchpad) emit(t EventType) {
+	s.events.publish(s.newEvent(t))
+}
+
+func (s *Scratchpad) newEvent(t EventType) *Event {
+	e := &Event{
+		Type:  t,
+		Time:  time.Now(),
+		ID:    s.id,
+		Pid:   s.Pid,
+		ConID: s.ConID,
+		Shape: s.shape,
+	}
+	if s.location != nil && !s.location.InScratchpad() {
+		e.Output = s.location.Output
+	}
+
+	return e
+}
+
+// isVisibleOn reports if the window is shown on the provided workspace.
+func (s *Scratchpad) isVisibleOn(ws *sway.Workspace) bool {
+	return s.Visibility(time.Now()) == VisibilityVisible && s.location.Workspace == ws.Name
+}
+
+// runCommand runs a command on the tracked window.
+func (s *Scratchpad) runCommand(ctx context.Context, format string) error {
+	if !s.IsTracked() {
 		return errNoMatchingNode
 	}
 
-	cmd := fmt.Sprintf("[pid=%d] scratchpad show", s.Pid)
+	cmd := fmt.Sprintf(format, s.target())
 
 	_, err := s.client.RunCommand(ctx, cmd)
 	if err != nil {
 		if strings.Contains(err.Error(), "No matching node") {
+			// window is gone
+			s.Untrack()
 			return errNoMatchingNode
 		}
 		return fmt.Errorf("s.client.RunCommand: %w", err)
@@ -107,34 +559,141 @@ func (s *Scratchpad) showScratchpad(ctx context.Context) error {
 	return nil
 }
 
-// Toggle tries to toggle the scratchpad or opens a new one.
-func (s *Scratchpad) Toggle(ctx context.Context) error {
-	err := s.showScratchpad(ctx)
-	if errors.Is(err, errNoMatchingNode) {
-		pid, err := s.spawnWindow(ctx)
-		if err != nil {
+// showOn shows the window on the provided (focused) workspace.
+// Window that is visible on another workspace is moved over.
+func (s *Scratchpad) showOn(ctx context.Context, ws *sway.Workspace) error {
+	err := s.runCommand(ctx, "[%s] scratchpad show")
+	if err != nil {
+		return err
+	}
+
+	s.moveTo(&core.NodeLocation{Workspace: ws.Name, Output: ws.Output})
+	return nil
+}
+
+// start claims an existing window or spawns a new process.
+// The window is shown on the provided workspace or kept hidden if it's nil.
+func (s *Scratchpad) start(ctx context.Context, ws *sway.Workspace) error {
+	// spawned window didn't show up yet
+	if s.IsPending(time.Now()) {
+		return fmt.Errorf("%w (pid %d)", errStillStarting, s.Pid)
+	}
+
+	// adopted window is gone for good
+	if !s.def.hasCommand() {
+		return errors.New("adopted window was closed and there is no command to start it again")
+	}
+
+	// window matching criteria might already exist
+	if s.def.Criteria != nil && s.def.ClaimExisting {
+		node, err := s.findCriteriaWindow(ctx)
+		if err == nil {
+			return s.Claim(ctx, node, ws)
+		} else if !errors.Is(err, errNoMatchingNode) {
 			return err
 		}
-		// update pid
-		s.Pid = pid
-	} else if err != nil {
+	}
+
+	return s.spawn(ws == nil)
+}
+
+// spawn starts the process. Its window is shown once it appears, unless hidden is set.
+func (s *Scratchpad) spawn(hidden bool) error {
+	pid, err := s.spawnWindow()
+	if err != nil {
+		return err
+	}
+	// update pid
+	s.Pid = pid
+	s.hideOnClaim = hidden
+	s.emit(EventSpawned)
+
+	return nil
+}
+
+// Show shows the hidden window or starts the scratchpad.
+// Window that is already visible (on any workspace) is left alone.
+func (s *Scratchpad) Show(ctx context.Context, ws *sway.Workspace) error {
+	if s.Visibility(time.Now()) == VisibilityVisible {
+		return nil
+	}
+
+	err := s.showOn(ctx, ws)
+	if !errors.Is(err, errNoMatchingNode) {
+		return err
+	}
+
+	return s.start(ctx, ws)
+}
+
+// Summon brings the window to the focused workspace and focuses it,
+// even if it's visible on another workspace.
+func (s *Scratchpad) Summon(ctx context.Context, ws *sway.Workspace) error {
+	var err error
+	if s.isVisibleOn(ws) {
+		err = s.runCommand(ctx, "[%s] focus")
+	} else {
+		err = s.showOn(ctx, ws)
+	}
+	if !errors.Is(err, errNoMatchingNode) {
+		return err
+	}
+
+	return s.start(ctx, ws)
+}
+
+// Hide moves the visible window back to the scratchpad.
+// Scratchpads with close on hide are killed instead.
+func (s *Scratchpad) Hide(ctx context.Context) error {
+	if s.Visibility(time.Now()) != VisibilityVisible {
+		return nil
+	}
+
+	if s.def.CloseOnHide {
+		return s.Kill(ctx)
+	}
+
+	err := s.runCommand(ctx, "[%s] move scratchpad")
+	if err != nil {
+		if errors.Is(err, errNoMatchingNode) {
+			return nil
+		}
 		return err
 	}
 
+	s.moveTo(&core.NodeLocation{Workspace: core.ScratchWorkspace})
 	return nil
 }
 
+// Toggle hides the window if it's visible on the focused workspace,
+// otherwise it summons it (or opens a new one).
+func (s *Scratchpad) Toggle(ctx context.Context, ws *sway.Workspace) error {
+	if s.isVisibleOn(ws) {
+		return s.Hide(ctx)
+	}
+
+	return s.Summon(ctx, ws)
+}
+
 // Shape describes scratchpad size and position on screen.
 type Shape struct {
-	X      int
-	Y      int
-	Width  int
-	Height int
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+func (s *Shape) String() string {
+	return fmt.Sprintf("%dx%d+%d+%d", s.Width, s.Height, s.X, s.Y)
 }
 
 func (eh *Scratchpad) CalculateWindowShape(out *core.Output) *Shape {
-	width := (eh.def.WindowWidth * out.Width) / out.PhysicalWidth
-	height := (eh.def.WindowHeight * out.Height) / out.PhysicalHeight
+	width := eh.def.WindowWidth.Pixels(out.Width, out.PhysicalWidth)
+	height := eh.def.WindowHeight.Pixels(out.Height, out.PhysicalHeight)
+
+	margin := Length{Value: eh.def.Margin, Unit: UnitMillimeter}
+	marginX := margin.Pixels(out.Width, out.PhysicalWidth)
+	marginY := margin.Pixels(out.Height, out.PhysicalHeight)
 
 	if height > out.Height {
 		height = out.Height
@@ -158,6 +717,35 @@ func (eh *Scratchpad) CalculateWindowShape(out *core.Output) *Shape {
 			width = (out.Width / 2)
 		}
 		x = (out.Width / 2) - width
+	default:
+		// positions anchored to edges - window has to fit between margins
+		availableWidth := max(out.Width-2*marginX, 0)
+		availableHeight := max(out.Height-2*marginY, 0)
+
+		if height > availableHeight {
+			height = availableHeight
+		}
+		if width > availableWidth || eh.def.Position == PositionDropdown {
+			width = availableWidth
+		}
+
+		// horizontal anchor
+		switch eh.def.Position {
+		case PositionTopLeft, PositionBottomLeft, PositionDropdown:
+			x = marginX
+		case PositionTopRight, PositionBottomRight:
+			x = out.Width - width - marginX
+		default:
+			x = (out.Width - width) / 2
+		}
+
+		// vertical anchor
+		switch eh.def.Position {
+		case PositionBottom, PositionBottomLeft, PositionBottomRight:
+			y = out.Height - height - marginY
+		default:
+			y = marginY
+		}
 	}
 
 	return &Shape{
@@ -170,9 +758,10 @@ func (eh *Scratchpad) CalculateWindowShape(out *core.Output) *Shape {
 
 // Reposition applies shape to scratchpad.
 func (s *Scratchpad) Reposition(ctx context.Context, shape *Shape) error {
+	target := s.target()
 	cmd := fmt.Sprintf(
-		"[pid=%d] resize set %d %d; [pid=%d] move absolute position %d %d",
-		s.Pid, shape.Width, shape.Height, s.Pid, shape.X, shape.Y,
+		"[%s] resize set %d %d; [%s] move absolute position %d %d",
+		target, shape.Width, shape.Height, target, shape.X, shape.Y,
 	)
 	fmt.Println(cmd)
 
@@ -181,5 +770,21 @@ func (s *Scratchpad) Reposition(ctx context.Context, shape *Shape) error {
 		return fmt.Errorf("s.client.RunCommand: %w", err)
 	}
 
+	s.shape = shape
+	// geometry is observed again from here
+	s.shapeApplied = true
+	s.observed = nil
+	s.emit(EventRepositioned)
+
+	// applied geometry is the reference - it includes window decorations
+	rect, err := s.windowRect(ctx)
+	if err != nil {
+		if errors.Is(err, errNoMatchingNode) {
+			return nil
+		}
+		return err
+	}
+	s.observed = rect
+
 	return nil
 }
@@ -0,0 +1,275 @@
+package scratch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kndndrj/sway-scripts/internal/core"
+)
+
+func TestParsePosition(t *testing.T) {
+	for pos := PositionCenter; pos <= PositionDropdown; pos++ {
+		parsed, err := parsePosition(pos.String())
+		require.NoError(t, err)
+		require.Equal(t, pos, parsed)
+	}
+
+	parsed, err := parsePosition("Top-Left")
+	require.NoError(t, err)
+	require.Equal(t, PositionTopLeft, parsed)
+
+	_, err = parsePosition("middle")
+	require.Error(t, err)
+}
+
+func TestScratchpad_CalculateWindowShape(t *testing.T) {
+	// 10 px per mm in both directions, offset by another output on the left
+	out := &core.Output{
+		Name:           "output-1",
+		Width:          2000,
+		Height:         1000,
+		PhysicalWidth:  200,
+		PhysicalHeight: 100,
+		X:              1000,
+		Y:              0,
+	}
+
+	testCases := []struct {
+		comment string
+
+		position     Position
+		windowWidth  int
+		windowHeight int
+		margin       int
+
+		expected Shape
+	}{
+		{
+			// +-----------------------+
+			// |                       |
+			// |      +---------+      |
+			// |      |         |      |
+			// |      +---------+      |
+			// |                       |
+			// +-----------------------+
+			comment:      "center",
+			position:     PositionCenter,
+			windowWidth:  50,
+			windowHeight: 30,
+			expected:     Shape{X: 1750, Y: 350, Width: 500, Height: 300},
+		},
+		{
+			comment:      "center: too big",
+			position:     PositionCenter,
+			windowWidth:  500,
+			windowHeight: 300,
+			expected:     Shape{X: 1000, Y: 0, Width: 2000, Height: 1000},
+		},
+		{
+			comment:      "left",
+			position:     PositionLeft,
+			windowWidth:  50,
+			windowHeight: 30,
+			expected:     Shape{X: 1500, Y: 350, Width: 500, Height: 300},
+		},
+		{
+			comment:      "right: capped to half of the screen",
+			position:     PositionRight,
+			windowWidth:  150,
+			windowHeight: 30,
+			expected:     Shape{X: 2000, Y: 350, Width: 1000, Height: 300},
+		},
+		{
+			// +-----------------------+
+			// |      +---------+      |
+			// |      |         |      |
+			// |      +---------+      |
+			// |                       |
+			// |                       |
+			// +-----------------------+
+			comment:      "top",
+			position:     PositionTop,
+			windowWidth:  50,
+			windowHeight: 30,
+			expected:     Shape{X: 1750, Y: 0, Width: 500, Height: 300},
+		},
+		{
+			comment:      "top with margin",
+			position:     PositionTop,
+			windowWidth:  50,
+			windowHeight: 30,
+			margin:       2,
+			expected:     Shape{X: 1750, Y: 20, Width: 500, Height: 300},
+		},
+		{
+			comment:      "bottom with margin",
+			position:     PositionBottom,
+			windowWidth:  50,
+			windowHeight: 30,
+			margin:       2,
+			expected:     Shape{X: 1750, Y: 680, Width: 500, Height: 300},
+		},
+		{
+			// +-----------------------+
+			// | +---------+           |
+			// | |         |           |
+			// | +---------+           |
+			// |                       |
+			// |                       |
+			// +-----------------------+
+			comment:      "top-left with margin",
+			position:     PositionTopLeft,
+			windowWidth:  50,
+			windowHeight: 30,
+			margin:       2,
+			expected:     Shape{X: 1020, Y: 20, Width: 500, Height: 300},
+		},
+		{
+			comment:      "top-right with margin",
+			position:     PositionTopRight,
+			windowWidth:  50,
+			windowHeight: 30,
+			margin:       2,
+			expected:     Shape{X: 2480, Y: 20, Width: 500, Height: 300},
+		},
+		{
+			comment:      "bottom-left",
+			position:     PositionBottomLeft,
+			windowWidth:  50,
+			windowHeight: 30,
+			expected:     Shape{X: 1000, Y: 700, Width: 500, Height: 300},
+		},
+		{
+			comment:      "bottom-right with margin",
+			position:     PositionBottomRight,
+			windowWidth:  50,
+			windowHeight: 30,
+			margin:       2,
+			expected:     Shape{X: 2480, Y: 680, Width: 500, Height: 300},
+		},
+		{
+			comment:      "bottom-right: too big to fit between margins",
+			position:     PositionBottomRight,
+			windowWidth:  300,
+			windowHeight: 200,
+			margin:       5,
+			expected:     Shape{X: 1050, Y: 50, Width: 1900, Height: 900},
+		},
+		{
+			// +-----------------------+
+			// |+---------------------+|
+			// ||                     ||
+			// |+---------------------+|
+			// |                       |
+			// |                       |
+			// +-----------------------+
+			comment:      "dropdown ignores preffered width",
+			position:     PositionDropdown,
+			windowWidth:  50,
+			windowHeight: 40,
+			expected:     Shape{X: 1000, Y: 0, Width: 2000, Height: 400},
+		},
+		{
+			comment:      "dropdown with margin",
+			position:     PositionDropdown,
+			windowWidth:  50,
+			windowHeight: 40,
+			margin:       1,
+			expected:     Shape{X: 1010, Y: 10, Width: 1980, Height: 400},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.comment, func(t *testing.T) {
+			def := &Definition{
+				Cmd:          "kitty",
+				Position:     tc.position,
+				WindowWidth:  Length{Value: tc.windowWidth},
+				WindowHeight: Length{Value: tc.windowHeight},
+				Margin:       tc.margin,
+			}
+			require.NoError(t, def.Validate())
+
+			sc := &Scratchpad{def: def}
+
+			shape := sc.CalculateWindowShape(out)
+			require.Equal(t, tc.expected, *shape)
+		})
+	}
+}
+
+func TestParseWindowSize(t *testing.T) {
+	w, h, err := parseWindowSize("80%x400px")
+	require.NoError(t, err)
+	require.Equal(t, Length{Value: 80, Unit: UnitPercent}, w)
+	require.Equal(t, Length{Value: 400, Unit: UnitPixel}, h)
+
+	w, h, err = parseWindowSize("200X100mm")
+	require.NoError(t, err)
+	require.Equal(t, Length{Value: 200, Unit: UnitMillimeter}, w)
+	require.Equal(t, Length{Value: 100, Unit: UnitMillimeter}, h)
+
+	for _, invalid := range []string{"", "80%", "0x100", "120%x10", "10px10", "10pxx10px10", "10inx10", "-5pxx10"} {
+		_, _, err := parseWindowSize(invalid)
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestLength_Pixels(t *testing.T) {
+	testCases := []struct {
+		comment  string
+		length   Length
+		pixels   int
+		physical int
+		expected int
+	}{
+		{comment: "millimeters", length: Length{Value: 50, Unit: UnitMillimeter}, pixels: 2000, physical: 200, expected: 500},
+		{comment: "pixels", length: Length{Value: 400, Unit: UnitPixel}, pixels: 2000, physical: 200, expected: 400},
+		{comment: "percent", length: Length{Value: 80, Unit: UnitPercent}, pixels: 2000, physical: 200, expected: 1600},
+		{comment: "unknown physical size", length: Length{Value: 254, Unit: UnitMillimeter}, pixels: 2000, physical: 0, expected: 960},
+		{comment: "percent with unknown physical size", length: Length{Value: 50, Unit: UnitPercent}, pixels: 2000, physical: 0, expected: 1000},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.comment, func(t *testing.T) {
+			require.Equal(t, tc.expected, tc.length.Pixels(tc.pixels, tc.physical))
+		})
+	}
+}
+
+func TestScratchpad_CalculateWindowShape_UnknownPhysicalSize(t *testing.T) {
+	out := &core.Output{Name: "headless-1", Width: 2000, Height: 1000}
+
+	sc := &Scratchpad{def: &Definition{
+		Cmd:          "kitty",
+		Position:     PositionCenter,
+		WindowWidth:  Length{Value: 50, Unit: UnitPercent},
+		WindowHeight: Length{Value: 254, Unit: UnitMillimeter},
+	}}
+
+	shape := sc.CalculateWindowShape(out)
+	require.Equal(t, Shape{X: 500, Y: 20, Width: 1000, Height: 960}, *shape)
+}
+
+func TestScratchpadMark(t *testing.T) {
+	r := require.New(t)
+
+	r.Equal("_scratch_term", scratchpadMark("term"))
+	r.Equal("_scratch_foo__bar", scratchpadMark("foo_bar"))
+	r.Equal("_scratch_foo_20bar", scratchpadMark("foo bar"))
+
+	// ids that used to share a mark
+	ids := []string{
+		"foo bar", "foo_bar", "foo.bar", "foo_20bar", "foo__bar",
+		"foot -e x_0_200mmx100mm", "foot_-e_x_0_200mmx100mm", "foot -e_x_0_200mmx100mm",
+	}
+	seen := make(map[string]string)
+	for _, id := range ids {
+		mark := scratchpadMark(id)
+		r.Regexp(`^[a-zA-Z0-9_-]+$`, mark)
+		other, ok := seen[mark]
+		r.False(ok, "%q and %q share mark %q", id, other, mark)
+		seen[mark] = id
+	}
+}
@@ -2,9 +2,15 @@ package scratch
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/joshuarubin/go-sway"
 
@@ -16,10 +22,20 @@ type Server struct {
 	client      sway.Client
 	outputCache *core.OutputCache
 	ninja       *core.NodeNinja
+	procs       *procTree
+	events      *hub
 
 	scratchpads map[string]*Scratchpad
+	// named definitions from the definitions file
+	definitions map[string]*Definition
+	// directory with output of scratchpad processes (empty means the server log)
+	logDir string
+	// log files by path - shared by scratchpads with the same id
+	logFiles map[string]*logFile
 
-	// mutex for sway client - it freezes sometimes without this for some reason.
+	// guards scratchpads, definitions and log files along with the visibility and
+	// supervision state of each scratchpad - requests, sway events and exits of
+	// spawned processes arrive concurrently.
 	mu sync.Mutex
 }
 
@@ -29,39 +45,172 @@ func NewServer(logger *log.Logger, c sway.Client, oc *core.OutputCache, ninja *c
 		client:      c,
 		outputCache: oc,
 		ninja:       ninja,
+		procs:       newProcTree("/proc"),
+		events:      newHub(),
 		scratchpads: make(map[string]*Scratchpad),
+		definitions: make(map[string]*Definition),
+		logFiles:    make(map[string]*logFile),
 	}
 }
 
-func (s *Server) findScratchpadWithPid(pid *uint32) (*Scratchpad, bool) {
-	if pid == nil || *pid == 0 {
-		return nil, false
+// SetDefinitions replaces named scratchpad definitions.
+// Running scratchpads pick up the new definition, their processes are left alone.
+func (s *Server) SetDefinitions(defs map[string]*Definition) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.definitions = defs
+
+	for id, sc := range s.scratchpads {
+		if def, ok := defs[id]; ok {
+			sc.def = def
+		}
+	}
+}
+
+// Prestart starts scratchpads from the definitions file marked with prestart.
+// Their windows go straight to the scratchpad. Scratchpads that are already running are skipped.
+func (s *Server) Prestart(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ids []string
+	for id, def := range s.definitions {
+		if def.Prestart {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+
+	var errs []error
+	for _, id := range ids {
+		sc, err := s.getScratchpad(id, nil)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if sc.IsRunning(time.Now()) {
+			continue
+		}
+
+		err = sc.start(ctx, nil)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: sc.start: %w", id, err))
+		}
 	}
 
-	p := int(*pid)
+	return errors.Join(errs...)
+}
 
-	for _, s := range s.scratchpads {
-		if s.Pid == p {
-			return s, true
+// findScratchpadForNode returns the scratchpad the node belongs to.
+func (s *Server) findScratchpadForNode(node *sway.Node, now time.Time) (*Scratchpad, bool) {
+	for _, sc := range s.scratchpads {
+		if sc.Matches(node, now) {
+			return sc, true
 		}
 	}
 
 	return nil, false
 }
 
+// matchNewWindow returns the pending scratchpad that the newly created window belongs to.
+func (s *Server) matchNewWindow(node *sway.Node, now time.Time) (*Scratchpad, bool) {
+	// refresh process trees of scratchpads still waiting for their windows
+	for _, sc := range s.scratchpads {
+		if !sc.IsPending(now) {
+			continue
+		}
+		err := sc.refreshDescendants(s.procs)
+		if err != nil {
+			s.log.Printf("sc.refreshDescendants: %s", err)
+		}
+	}
+
+	sc, ok := s.findScratchpadForNode(node, now)
+	if !ok || sc.IsTracked() {
+		return nil, false
+	}
+	return sc, true
+}
+
+// updateState tracks the scratchpad window from window events.
+func (s *Server) updateState(ctx context.Context, e sway.WindowEvent, now time.Time) error {
+	sc, ok := s.findScratchpadForNode(&e.Container, now)
+	if !ok || !sc.IsTracked() {
+		return nil
+	}
+
+	if e.Change == sway.WindowClose {
+		sc.Untrack()
+		s.checkStopped(sc)
+		return nil
+	}
+
+	loc, err := s.ninja.FindNodeLocation(ctx, sc.ConID)
+	if err != nil {
+		if errors.Is(err, core.ErrNodeNotFound) {
+			sc.Untrack()
+			s.checkStopped(sc)
+			return nil
+		}
+		return fmt.Errorf("s.ninja.FindNodeLocation: %w", err)
+	}
+	sc.SetLocation(loc)
+
+	return nil
+}
+
 // OnWindow handler should get called on window events.
-func (s *Server) OnWindow(ctx context.Context) error {
+func (s *Server) OnWindow(ctx context.Context, e sway.WindowEvent) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	// claim windows of spawned scratchpads
+	if e.Change == sway.WindowNew {
+		sc, ok := s.matchNewWindow(&e.Container, time.Now())
+		if !ok {
+			return nil
+		}
+
+		// respawned windows stay hidden
+		var ws *sway.Workspace
+		if !sc.hideOnClaim {
+			var err error
+			ws, err = s.ninja.FindFocusedWorkspace(ctx)
+			if err != nil {
+				return fmt.Errorf("s.ninja.FindFocusedWorkspace: %w", err)
+			}
+		}
+
+		err := sc.Claim(ctx, &e.Container, ws)
+		if err != nil {
+			return fmt.Errorf("sc.Claim: %w", err)
+		}
+		return nil
+	}
+
+	err := s.updateState(ctx, e, time.Now())
+	if err != nil {
+		return err
+	}
+	if e.Change == sway.WindowClose {
+		return nil
+	}
+
+	// user might have moved or resized the window
+	err = s.observeShape(ctx, &e.Container, time.Now())
+	if err != nil {
+		return err
+	}
+
 	focused, err := s.ninja.FindFocusedNode(ctx)
 	if err != nil {
 		return fmt.Errorf("s.ninja.FindFocusedNode: %w", err)
 	}
 
-	// ignore all windows without the same pid as known scratchpads
-	scratchpad, ok := s.findScratchpadWithPid(focused.PID)
-	if !ok {
+	// ignore all windows that don't belong to known scratchpads
+	scratchpad, ok := s.findScratchpadForNode(focused, time.Now())
+	if !ok || !scratchpad.IsTracked() {
 		return nil
 	}
 
@@ -76,7 +225,11 @@ func (s *Server) OnWindow(ctx context.Context) error {
 	}
 
 	// calculate window dimensions based on prefferences and display size
-	shape := scratchpad.CalculateWindowShape(out)
+	// (unless the user set them)
+	shape := scratchpad.ShapeOn(out)
+	if !scratchpad.NeedsReposition(shape) {
+		return nil
+	}
 
 	err = scratchpad.Reposition(ctx, shape)
 	if err != nil {
@@ -85,38 +238,503 @@ func (s *Server) OnWindow(ctx context.Context) error {
 	return nil
 }
 
+// observeShape records geometry of the window if the node is a visible scratchpad window.
+func (s *Server) observeShape(ctx context.Context, node *sway.Node, now time.Time) error {
+	sc, ok := s.findScratchpadForNode(node, now)
+	if !ok || sc.Visibility(now) != VisibilityVisible {
+		return nil
+	}
+
+	out, err := s.outputCache.Get(ctx, sc.location.Output)
+	if err != nil {
+		return fmt.Errorf("s.outputCache.Get: %w", err)
+	}
+
+	_, err = sc.observeWindow(ctx, out)
+	if err != nil {
+		return fmt.Errorf("sc.observeWindow: %w", err)
+	}
+	return nil
+}
+
 // OnWorkspace handler should get called on workspace events.
 func (s *Server) OnWorkspace(ctx context.Context) error {
 	s.outputCache.Invalidate()
 	return nil
 }
 
-// ToggleScratchpad toggles a specified scratchpad.
-func (s *Server) ToggleScratchpad(ctx context.Context, id string, def *Definition) error {
+// Command is an action on scratchpads requested over the socket.
+type Command string
+
+const (
+	CommandToggle     Command = "toggle"
+	CommandShow       Command = "show"
+	CommandHide       Command = "hide"
+	CommandSummon     Command = "summon"
+	CommandHideAll    Command = "hide-all"
+	CommandCycle      Command = "cycle"
+	CommandList       Command = "list"
+	CommandSubscribe  Command = "subscribe"
+	CommandAdopt      Command = "adopt"
+	CommandRelease    Command = "release"
+	CommandKill       Command = "kill"
+	CommandRestart    Command = "restart"
+	CommandLogs       Command = "logs"
+	CommandResetShape Command = "reset-shape"
+)
+
+// getScratchpad returns a scratchpad with the provided id, it's created if it doesn't exist yet.
+// If no definition is provided, the named definition with the same id is used.
+// Names that are not defined are run as a command with the default placement.
+func (s *Server) getScratchpad(id string, def *Definition) (*Scratchpad, error) {
+	if sc, ok := s.scratchpads[id]; ok {
+		return sc, nil
+	}
+
+	if def == nil {
+		named, ok := s.definitions[id]
+		if !ok {
+			named = commandDefinition(id)
+		}
+		def = named
+	}
+
+	sc, err := NewScratchpad(s.log, s.client, id, def)
+	if err != nil {
+		return nil, err
+	}
+	s.addScratchpad(sc)
+
+	return sc, nil
+}
+
+// lookupScratchpad returns a scratchpad with the provided id without creating it.
+func (s *Server) lookupScratchpad(id string) (*Scratchpad, error) {
+	sc, ok := s.scratchpads[id]
+	if !ok {
+		return nil, fmt.Errorf("scratchpad %q is not running", id)
+	}
+	return sc, nil
+}
+
+// addScratchpad registers the scratchpad with the server.
+func (s *Server) addScratchpad(sc *Scratchpad) {
+	sc.events = s.events
+	sc.onExit = func(pid int, err error) {
+		s.handleExit(sc, pid, err)
+	}
+	if s.logDir != "" {
+		sc.output = s.logFile(sc.id)
+	}
+	// replaced scratchpad is gone for good
+	if prev, ok := s.scratchpads[sc.id]; ok && prev != sc {
+		s.closeOutput(prev)
+	}
+	s.scratchpads[sc.id] = sc
+}
+
+// logFile returns the log file of the scratchpad with the provided id.
+func (s *Server) logFile(id string) *logFile {
+	path := filepath.Join(s.logDir, logFileName(id))
+	if f, ok := s.logFiles[path]; ok {
+		return f
+	}
+
+	f := newLogFile(s.log, path, maxLogSize)
+	s.logFiles[path] = f
+	return f
+}
+
+// closeOutput closes the log file of a dropped scratchpad.
+// It's opened again if its process keeps writing.
+func (s *Server) closeOutput(sc *Scratchpad) {
+	f, ok := sc.output.(*logFile)
+	if !ok {
+		return
+	}
+
+	err := f.Close()
+	if err != nil {
+		s.log.Printf("%s: f.Close: %s", sc.id, err)
+	}
+}
+
+// SetLogDir makes scratchpad processes write their output to "<dir>/<id>.log".
+// Scratchpads that are already known keep their output.
+func (s *Server) SetLogDir(dir string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// toggle existing scratchpad
-	if sc, ok := s.scratchpads[id]; ok {
-		err := sc.Toggle(ctx)
+	s.logDir = dir
+}
+
+// LogPath returns the log file of the scratchpad with the provided id.
+func (s *Server) LogPath(id string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.logDir == "" {
+		return "", errors.New("scratchpad output is not logged to files")
+	}
+
+	path := filepath.Join(s.logDir, logFileName(id))
+
+	// logs of scratchpads from previous runs are still there
+	_, known := s.scratchpads[id]
+	_, named := s.definitions[id]
+	if !known && !named {
+		_, err := os.Stat(path)
+		if err != nil {
+			return "", fmt.Errorf("unknown scratchpad: %q", id)
+		}
+	}
+
+	return path, nil
+}
+
+// Run executes the command on the scratchpad with the provided id.
+// If no definition is provided, the named definition with the same id is used.
+// For CommandCycle, id is the name of the group.
+func (s *Server) Run(ctx context.Context, cmd Command, id string, def *Definition) error {
+	return s.RunFrom(ctx, nil, cmd, id, def)
+}
+
+// RunFrom is like Run, but remembers the caller, so that scratchpads
+// with Definition.InheritCaller start in its working directory and environment.
+func (s *Server) RunFrom(ctx context.Context, caller *Caller, cmd Command, id string, def *Definition) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch cmd {
+	case CommandHideAll:
+		return s.hideAll(ctx)
+	case CommandCycle:
+		return s.cycle(ctx, id)
+	case CommandAdopt:
+		return s.adopt(ctx, id, def)
+	case CommandRelease:
+		return s.release(ctx, id)
+	case CommandResetShape:
+		return s.resetShape(ctx, id)
+	case "":
+		cmd = CommandToggle
+	case CommandToggle, CommandShow, CommandHide, CommandSummon, CommandKill, CommandRestart:
+	default:
+		return fmt.Errorf("unknown command: %q", cmd)
+	}
+
+	// only showing a scratchpad starts it
+	var sc *Scratchpad
+	var err error
+	switch cmd {
+	case CommandHide, CommandKill, CommandRestart:
+		sc, err = s.lookupScratchpad(id)
+	default:
+		sc, err = s.getScratchpad(id, def)
+	}
+	if err != nil {
+		return err
+	}
+	// the caller's context is kept only for scratchpads that use it
+	if caller != nil && sc.def.InheritCaller {
+		sc.caller = caller
+	}
+
+	// commands that don't depend on the focused workspace
+	switch cmd {
+	case CommandHide:
+		err := sc.Hide(ctx)
+		if err != nil {
+			return fmt.Errorf("sc.Hide: %w", err)
+		}
+		return nil
+	case CommandKill:
+		err := sc.Kill(ctx)
+		if err != nil {
+			return fmt.Errorf("sc.Kill: %w", err)
+		}
+		return nil
+	case CommandRestart:
+		err := sc.Restart(ctx)
+		if err != nil {
+			return fmt.Errorf("sc.Restart: %w", err)
+		}
+		return nil
+	}
+
+	ws, err := s.ninja.FindFocusedWorkspace(ctx)
+	if err != nil {
+		return fmt.Errorf("s.ninja.FindFocusedWorkspace: %w", err)
+	}
+
+	// other members of the group are hidden unless the window is toggled off
+	toggleOff := cmd == CommandToggle && sc.isVisibleOn(ws)
+	if !toggleOff {
+		err = s.hideGroup(ctx, sc)
+		if err != nil {
+			return err
+		}
+	}
+
+	switch cmd {
+	case CommandShow:
+		err = sc.Show(ctx, ws)
+		if err != nil {
+			return fmt.Errorf("sc.Show: %w", err)
+		}
+	case CommandSummon:
+		err = sc.Summon(ctx, ws)
+		if err != nil {
+			return fmt.Errorf("sc.Summon: %w", err)
+		}
+	case CommandToggle:
+		err = sc.Toggle(ctx, ws)
 		if err != nil {
 			return fmt.Errorf("sc.Toggle: %w", err)
 		}
+	}
+
+	return nil
+}
+
+// hideAll hides all visible scratchpads.
+func (s *Server) hideAll(ctx context.Context) error {
+	var errs []error
+	for id, sc := range s.scratchpads {
+		err := sc.Hide(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: sc.Hide: %w", id, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// hideGroup hides other visible scratchpads in the same group as the provided one.
+func (s *Server) hideGroup(ctx context.Context, sc *Scratchpad) error {
+	if sc.def.Group == "" {
 		return nil
 	}
 
-	// otherwise create a new scratchpad and toggle (open it)
-	sc, err := NewScratchpad(s.log, s.client, def)
+	var errs []error
+	for id, other := range s.scratchpads {
+		if other == sc || other.def.Group != sc.def.Group {
+			continue
+		}
+		err := other.Hide(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: other.Hide: %w", id, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// groupMembers returns sorted ids of running and defined scratchpads in the group.
+func (s *Server) groupMembers(group string) []string {
+	var ids []string
+	for id, sc := range s.scratchpads {
+		if sc.def.Group == group {
+			ids = append(ids, id)
+		}
+	}
+	for id, def := range s.definitions {
+		if _, ok := s.scratchpads[id]; !ok && def.Group == group {
+			ids = append(ids, id)
+		}
+	}
+
+	slices.Sort(ids)
+	return ids
+}
+
+// cycle summons the group member following the visible one.
+func (s *Server) cycle(ctx context.Context, group string) error {
+	members := s.groupMembers(group)
+	if group == "" || len(members) < 1 {
+		return fmt.Errorf("unknown group: %q", group)
+	}
+
+	ws, err := s.ninja.FindFocusedWorkspace(ctx)
+	if err != nil {
+		return fmt.Errorf("s.ninja.FindFocusedWorkspace: %w", err)
+	}
+
+	// member visible on the focused workspace has priority
+	current := -1
+	now := time.Now()
+	for i, id := range members {
+		sc, ok := s.scratchpads[id]
+		if !ok || sc.Visibility(now) != VisibilityVisible {
+			continue
+		}
+		if sc.isVisibleOn(ws) {
+			current = i
+			break
+		}
+		if current < 0 {
+			current = i
+		}
+	}
+
+	sc, err := s.getScratchpad(members[(current+1)%len(members)], nil)
 	if err != nil {
 		return err
 	}
 
-	err = sc.Toggle(ctx)
+	err = s.hideGroup(ctx, sc)
 	if err != nil {
-		return fmt.Errorf("sc.Toggle: %w", err)
+		return err
 	}
 
-	s.scratchpads[id] = sc
+	err = sc.Summon(ctx, ws)
+	if err != nil {
+		return fmt.Errorf("sc.Summon: %w", err)
+	}
+
+	return nil
+}
+
+// Subscribe returns a channel of scratchpad events and a function to cancel the subscription.
+// The channel is closed if the subscriber doesn't keep up with events.
+func (s *Server) Subscribe() (<-chan *Event, func()) {
+	return s.events.subscribe()
+}
+
+// Status describes a single scratchpad.
+type Status struct {
+	ID         string     `json:"id"`
+	Cmd        string     `json:"cmd"`
+	Group      string     `json:"group,omitempty"`
+	Pid        int        `json:"pid"`
+	ConID      int64      `json:"con_id"`
+	Visibility Visibility `json:"visibility"`
+	// Output the window is visible on (empty if hidden).
+	Output string `json:"output"`
+	// Shape last applied to the window (nil if not shown yet).
+	Shape *Shape `json:"shape"`
+}
+
+// List returns status of all known scratchpads sorted by id.
+func (s *Server) List() []*Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	list := make([]*Status, 0, len(s.scratchpads))
+	for id, sc := range s.scratchpads {
+		st := &Status{
+			ID:         id,
+			Cmd:        sc.def.Command(),
+			Group:      sc.def.Group,
+			Pid:        sc.Pid,
+			ConID:      sc.ConID,
+			Visibility: sc.Visibility(now),
+			Shape:      sc.shape,
+		}
+		if st.Visibility == VisibilityVisible {
+			st.Output = sc.location.Output
+		}
+		list = append(list, st)
+	}
+
+	slices.SortFunc(list, func(a, b *Status) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	return list
+}
+
+// adopt turns the focused window into a scratchpad with the provided id.
+// Definition describes the placement only, adopted scratchpads have no command.
+func (s *Server) adopt(ctx context.Context, id string, def *Definition) error {
+	if id == "" {
+		return errors.New("no scratchpad id provided")
+	}
+	if def == nil {
+		return errors.New("no definition provided")
+	}
+	def.Cmd = ""
+	def.Argv = nil
+	err := def.validateWindow()
+	if err != nil {
+		return fmt.Errorf("def.validateWindow: %w", err)
+	}
+
+	now := time.Now()
+
+	if sc, ok := s.scratchpads[id]; ok && sc.Visibility(now) != VisibilityNone {
+		return fmt.Errorf("scratchpad %q is already running", id)
+	}
+
+	node, err := s.ninja.FindFocusedNode(ctx)
+	if err != nil {
+		return fmt.Errorf("s.ninja.FindFocusedNode: %w", err)
+	}
+	if !isWindow(node) {
+		return errors.New("focused node is not a window")
+	}
+	if other, ok := s.findScratchpadForNode(node, now); ok {
+		return fmt.Errorf("window already belongs to scratchpad %q", other.id)
+	}
+
+	ws, err := s.ninja.FindFocusedWorkspace(ctx)
+	if err != nil {
+		return fmt.Errorf("s.ninja.FindFocusedWorkspace: %w", err)
+	}
+
+	sc := newScratchpad(s.log, s.client, id, def)
+	sc.events = s.events
+
+	err = sc.Claim(ctx, node, ws)
+	if err != nil {
+		return fmt.Errorf("sc.Claim: %w", err)
+	}
+	s.addScratchpad(sc)
+
+	return nil
+}
+
+// release returns the scratchpad window to tiling and forgets the scratchpad.
+// Spawned process is left running.
+func (s *Server) release(ctx context.Context, id string) error {
+	sc, ok := s.scratchpads[id]
+	if !ok || !sc.IsTracked() {
+		return fmt.Errorf("scratchpad %q has no window", id)
+	}
+
+	err := sc.Release(ctx)
+	if err != nil {
+		return fmt.Errorf("sc.Release: %w", err)
+	}
+	delete(s.scratchpads, id)
+	s.closeOutput(sc)
+
+	return nil
+}
+
+// resetShape drops shapes set by the user and applies the calculated one to a visible window.
+func (s *Server) resetShape(ctx context.Context, id string) error {
+	sc, err := s.lookupScratchpad(id)
+	if err != nil {
+		return err
+	}
+
+	sc.ResetShape()
+	if sc.Visibility(time.Now()) != VisibilityVisible {
+		return nil
+	}
+
+	out, err := s.outputCache.Get(ctx, sc.location.Output)
+	if err != nil {
+		return fmt.Errorf("s.outputCache.Get: %w", err)
+	}
+
+	err = sc.Reposition(ctx, sc.ShapeOn(out))
+	if err != nil {
+		return fmt.Errorf("sc.Reposition: %w", err)
+	}
 
 	return nil
 }
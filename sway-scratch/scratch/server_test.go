@@ -0,0 +1,641 @@
+package scratch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/joshuarubin/go-sway"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kndndrj/sway-scripts/internal/core"
+)
+
+// fakeClient records commands sent to sway and serves a static tree.
+type fakeClient struct {
+	sway.Client
+
+	commands   []string
+	tree       *sway.Node
+	workspaces []sway.Workspace
+}
+
+func (c *fakeClient) RunCommand(_ context.Context, cmd string) ([]sway.RunCommandReply, error) {
+	c.commands = append(c.commands, cmd)
+	return nil, nil
+}
+
+func (c *fakeClient) GetTree(context.Context) (*sway.Node, error) {
+	if c.tree == nil {
+		return &sway.Node{Type: sway.NodeRoot}, nil
+	}
+	return c.tree, nil
+}
+
+func (c *fakeClient) GetWorkspaces(context.Context) ([]sway.Workspace, error) {
+	return c.workspaces, nil
+}
+
+// newTree returns a tree with a single output and workspaces "1" and "2".
+// Window is placed on the workspace with the provided name.
+func newTree(window *sway.Node, workspace string) *sway.Node {
+	ws1 := &sway.Node{ID: 2, Type: sway.NodeWorkspace, Name: "1"}
+	ws2 := &sway.Node{ID: 3, Type: sway.NodeWorkspace, Name: "2"}
+	scratch := &sway.Node{ID: 4, Type: sway.NodeWorkspace, Name: core.ScratchWorkspace}
+
+	for _, ws := range []*sway.Node{ws1, ws2, scratch} {
+		if ws.Name == workspace {
+			ws.FloatingNodes = append(ws.FloatingNodes, window)
+		}
+	}
+
+	return &sway.Node{
+		ID:   1,
+		Type: sway.NodeRoot,
+		Nodes: []*sway.Node{
+			{ID: 5, Type: sway.NodeOutput, Name: "__i3", Nodes: []*sway.Node{scratch}},
+			{ID: 6, Type: sway.NodeOutput, Name: "eDP-1", Nodes: []*sway.Node{ws1, ws2}},
+		},
+	}
+}
+
+func newWindowEvent(id int64, pid uint32, appID string) sway.WindowEvent {
+	node := sway.Node{
+		ID:   id,
+		Type: sway.NodeCon,
+		PID:  &pid,
+	}
+	if appID != "" {
+		node.AppID = &appID
+	}
+
+	return sway.WindowEvent{
+		Change:    sway.WindowNew,
+		Container: node,
+	}
+}
+
+func TestServer_ClaimNewWindows(t *testing.T) {
+	r := require.New(t)
+
+	client := &fakeClient{
+		workspaces: []sway.Workspace{{Name: "1", Output: "eDP-1", Focused: true}},
+	}
+	logger := log.New(io.Discard, "", 0)
+
+	srv := NewServer(logger, client, nil, core.NewNodeNinja(client))
+	srv.procs = newProcTree("testdata/proc")
+
+	addScratchpad := func(id string, def *Definition, pid int, pendingUntil time.Time) {
+		sc, err := NewScratchpad(logger, client, id, def)
+		r.NoError(err)
+		sc.Pid = pid
+		sc.pendingUntil = pendingUntil
+		srv.scratchpads[id] = sc
+	}
+
+	now := time.Now()
+
+	// spawned through a shell wrapper (see testdata/proc)
+	addScratchpad("term", &Definition{Cmd: "kitty-launch", WindowWidth: Length{Value: 1}, WindowHeight: Length{Value: 1}}, 1000, now.Add(time.Minute))
+	// window handed over to another process
+	addScratchpad("emacs", &Definition{Cmd: "emacsclient -c", WindowWidth: Length{Value: 1}, WindowHeight: Length{Value: 1}, Criteria: &Criteria{AppID: "emacs"}}, 6000, now.Add(time.Minute))
+	// took too long to show up
+	addScratchpad("foot", &Definition{Cmd: "foot", WindowWidth: Length{Value: 1}, WindowHeight: Length{Value: 1}}, 2000, now.Add(-time.Second))
+
+	events := []struct {
+		comment  string
+		event    sway.WindowEvent
+		expected []string
+	}{
+		{
+			comment: "unrelated window",
+			event:   newWindowEvent(10, 3000, ""),
+		},
+		{
+			comment: "window of a descendant process",
+			event:   newWindowEvent(11, 1002, ""),
+			expected: []string{
+				"[con_id=11] mark --add _scratch_term",
+				`[con_mark="^_scratch_term$"] move scratchpad; [con_mark="^_scratch_term$"] scratchpad show`,
+			},
+		},
+		{
+			comment: "second window of the same process is not claimed",
+			event:   newWindowEvent(12, 1003, ""),
+		},
+		{
+			comment: "window with unrelated pid matching criteria",
+			event:   newWindowEvent(13, 7777, "emacs"),
+			expected: []string{
+				"[con_id=13] mark --add _scratch_emacs",
+				`[con_mark="^_scratch_emacs$"] move scratchpad; [con_mark="^_scratch_emacs$"] scratchpad show`,
+			},
+		},
+		{
+			comment: "another window matching criteria is not claimed",
+			event:   newWindowEvent(14, 7777, "emacs"),
+		},
+		{
+			comment: "window after claim timeout",
+			event:   newWindowEvent(15, 2001, ""),
+		},
+	}
+
+	// pending scratchpad is not started again
+	r.ErrorIs(srv.Run(context.Background(), CommandShow, "term", nil), errStillStarting)
+	r.Equal(1000, srv.scratchpads["term"].Pid)
+
+	for _, e := range events {
+		client.commands = nil
+
+		r.NoError(srv.OnWindow(context.Background(), e.event), e.comment)
+		r.Equal(e.expected, client.commands, e.comment)
+	}
+
+	r.Equal(int64(11), srv.scratchpads["term"].ConID)
+	r.Equal(int64(13), srv.scratchpads["emacs"].ConID)
+	r.False(srv.scratchpads["foot"].IsTracked())
+}
+
+func TestServer_Visibility(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	client := &fakeClient{
+		workspaces: []sway.Workspace{{Name: "1", Output: "eDP-1", Focused: true}},
+	}
+	logger := log.New(io.Discard, "", 0)
+
+	srv := NewServer(logger, client, nil, core.NewNodeNinja(client))
+	srv.SetDefinitions(map[string]*Definition{
+		"term": {Cmd: "kitty", WindowWidth: Length{Value: 1}, WindowHeight: Length{Value: 1}},
+	})
+
+	sc, err := srv.getScratchpad("term", nil)
+	r.NoError(err)
+	r.Equal(VisibilityNone, sc.Visibility(time.Now()))
+
+	events, unsubscribe := srv.Subscribe()
+	defer unsubscribe()
+
+	pid := uint32(1000)
+	window := &sway.Node{ID: 10, Type: sway.NodeFloatingCon, PID: &pid, Marks: []string{"_scratch_term"}}
+	sc.ConID = window.ID
+
+	const target = `[con_mark="^_scratch_term$"]`
+
+	// state follows window events
+	move := func(workspace string) {
+		client.tree = newTree(window, workspace)
+		r.NoError(srv.updateState(ctx, sway.WindowEvent{Change: sway.WindowMove, Container: *window}, time.Now()))
+	}
+
+	testCases := []struct {
+		comment   string
+		workspace string
+		cmd       Command
+
+		expectedCommands   []string
+		expectedVisibility Visibility
+	}{
+		{
+			comment:            "hide hidden window",
+			workspace:          core.ScratchWorkspace,
+			cmd:                CommandHide,
+			expectedVisibility: VisibilityHidden,
+		},
+		{
+			comment:            "show hidden window",
+			workspace:          core.ScratchWorkspace,
+			cmd:                CommandShow,
+			expectedCommands:   []string{target + " scratchpad show"},
+			expectedVisibility: VisibilityVisible,
+		},
+		{
+			comment:            "show window visible on another workspace",
+			workspace:          "2",
+			cmd:                CommandShow,
+			expectedVisibility: VisibilityVisible,
+		},
+		{
+			comment:            "summon window visible on another workspace",
+			workspace:          "2",
+			cmd:                CommandSummon,
+			expectedCommands:   []string{target + " scratchpad show"},
+			expectedVisibility: VisibilityVisible,
+		},
+		{
+			comment:            "summon window visible on focused workspace",
+			workspace:          "1",
+			cmd:                CommandSummon,
+			expectedCommands:   []string{target + " focus"},
+			expectedVisibility: VisibilityVisible,
+		},
+		{
+			comment:            "toggle window visible on another workspace",
+			workspace:          "2",
+			cmd:                CommandToggle,
+			expectedCommands:   []string{target + " scratchpad show"},
+			expectedVisibility: VisibilityVisible,
+		},
+		{
+			comment:            "toggle window visible on focused workspace",
+			workspace:          "1",
+			cmd:                CommandToggle,
+			expectedCommands:   []string{target + " move scratchpad"},
+			expectedVisibility: VisibilityHidden,
+		},
+		{
+			comment:            "hide all",
+			workspace:          "2",
+			cmd:                CommandHideAll,
+			expectedCommands:   []string{target + " move scratchpad"},
+			expectedVisibility: VisibilityHidden,
+		},
+	}
+
+	for _, tc := range testCases {
+		move(tc.workspace)
+		client.commands = nil
+
+		r.NoError(srv.Run(ctx, tc.cmd, "term", nil), tc.comment)
+		r.Equal(tc.expectedCommands, client.commands, tc.comment)
+		r.Equal(tc.expectedVisibility, sc.Visibility(time.Now()), tc.comment)
+	}
+
+	// closed window is forgotten
+	r.NoError(srv.updateState(ctx, sway.WindowEvent{Change: sway.WindowClose, Container: *window}, time.Now()))
+	r.Equal(VisibilityNone, sc.Visibility(time.Now()))
+	r.False(sc.IsTracked())
+
+	// state changes are published, including the ones observed from events
+	unsubscribe()
+	var emitted []EventType
+	for e := range events {
+		r.Equal("term", e.ID)
+		emitted = append(emitted, e.Type)
+	}
+	r.Equal([]EventType{
+		EventShown,  // show hidden window
+		EventShown,  // moved to another workspace
+		EventShown,  // summoned back
+		EventShown,  // moved to another workspace
+		EventShown,  // toggled back
+		EventHidden, // toggled off
+		EventShown,  // moved to another workspace
+		EventHidden, // hide all
+		EventExited, // closed
+	}, emitted)
+}
+
+func TestServer_Groups(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	client := &fakeClient{
+		workspaces: []sway.Workspace{{Name: "1", Output: "eDP-1", Focused: true}},
+	}
+	logger := log.New(io.Discard, "", 0)
+
+	srv := NewServer(logger, client, nil, core.NewNodeNinja(client))
+
+	newDef := func(group string) *Definition {
+		return &Definition{Cmd: "kitty", WindowWidth: Length{Value: 1}, WindowHeight: Length{Value: 1}, Group: group}
+	}
+	srv.SetDefinitions(map[string]*Definition{
+		"term":  newDef("panels"),
+		"notes": newDef("panels"),
+		"music": newDef(""),
+	})
+
+	// all scratchpads are running and hidden
+	for i, id := range []string{"term", "notes", "music"} {
+		sc, err := srv.getScratchpad(id, nil)
+		r.NoError(err)
+		sc.ConID = int64(10 + i)
+		sc.SetLocation(&core.NodeLocation{Workspace: core.ScratchWorkspace})
+	}
+	srv.scratchpads["term"].SetLocation(&core.NodeLocation{Workspace: "1", Output: "eDP-1"})
+
+	target := func(id string) string {
+		return fmt.Sprintf(`[con_mark="^_scratch_%s$"]`, id)
+	}
+
+	testCases := []struct {
+		comment string
+		cmd     Command
+		id      string
+
+		expectedCommands []string
+		expectedVisible  []string
+	}{
+		{
+			comment:          "scratchpad without a group doesn't hide others",
+			cmd:              CommandShow,
+			id:               "music",
+			expectedCommands: []string{target("music") + " scratchpad show"},
+			expectedVisible:  []string{"music", "term"},
+		},
+		{
+			comment:          "visible member of the group is hidden",
+			cmd:              CommandToggle,
+			id:               "notes",
+			expectedCommands: []string{target("term") + " move scratchpad", target("notes") + " scratchpad show"},
+			expectedVisible:  []string{"music", "notes"},
+		},
+		{
+			comment:          "toggling off doesn't touch other members",
+			cmd:              CommandToggle,
+			id:               "notes",
+			expectedCommands: []string{target("notes") + " move scratchpad"},
+			expectedVisible:  []string{"music"},
+		},
+		{
+			comment:          "cycle without a visible member starts with the first one",
+			cmd:              CommandCycle,
+			id:               "panels",
+			expectedCommands: []string{target("notes") + " scratchpad show"},
+			expectedVisible:  []string{"music", "notes"},
+		},
+		{
+			comment:          "cycle to the next member",
+			cmd:              CommandCycle,
+			id:               "panels",
+			expectedCommands: []string{target("notes") + " move scratchpad", target("term") + " scratchpad show"},
+			expectedVisible:  []string{"music", "term"},
+		},
+		{
+			comment:          "cycle wraps around",
+			cmd:              CommandCycle,
+			id:               "panels",
+			expectedCommands: []string{target("term") + " move scratchpad", target("notes") + " scratchpad show"},
+			expectedVisible:  []string{"music", "notes"},
+		},
+	}
+
+	for _, tc := range testCases {
+		client.commands = nil
+
+		r.NoError(srv.Run(ctx, tc.cmd, tc.id, nil), tc.comment)
+		r.Equal(tc.expectedCommands, client.commands, tc.comment)
+
+		var visible []string
+		for id, sc := range srv.scratchpads {
+			if sc.Visibility(time.Now()) == VisibilityVisible {
+				visible = append(visible, id)
+			}
+		}
+		slices.Sort(visible)
+		r.Equal(tc.expectedVisible, visible, tc.comment)
+	}
+
+	r.Error(srv.Run(ctx, CommandCycle, "unknown", nil))
+}
+
+func TestServer_Prestart(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	appID := "obsidian"
+	pid := uint32(7777)
+	existing := &sway.Node{ID: 30, Type: sway.NodeCon, PID: &pid, AppID: &appID}
+
+	client := &fakeClient{
+		tree:       newTree(existing, "1"),
+		workspaces: []sway.Workspace{{Name: "1", Output: "eDP-1", Focused: true}},
+	}
+	srv := NewServer(log.New(io.Discard, "", 0), client, nil, core.NewNodeNinja(client))
+	srv.SetDefinitions(map[string]*Definition{
+		"notes": {
+			Cmd: "obsidian", WindowWidth: Length{Value: 1}, WindowHeight: Length{Value: 1},
+			Criteria: &Criteria{AppID: "obsidian"}, ClaimExisting: true, Prestart: true,
+		},
+		"term":   {Cmd: "sleep 1", WindowWidth: Length{Value: 1}, WindowHeight: Length{Value: 1}, Prestart: true},
+		"lazy":   {Cmd: "sleep 1", WindowWidth: Length{Value: 1}, WindowHeight: Length{Value: 1}},
+		"daemon": {Cmd: "sleep 10", WindowWidth: Length{Value: 1}, WindowHeight: Length{Value: 1}, Prestart: true},
+	})
+
+	// existing window matching criteria is claimed and kept hidden
+	r.NoError(srv.Prestart(ctx))
+	r.Equal([]string{
+		"[con_id=30] mark --add _scratch_notes",
+		`[con_mark="^_scratch_notes$"] move scratchpad`,
+	}, client.commands)
+	r.Equal(VisibilityHidden, srv.scratchpads["notes"].Visibility(time.Now()))
+
+	// spawned process is waiting for its window
+	srv.mu.Lock()
+	term := srv.scratchpads["term"]
+	r.Equal(VisibilityStarting, term.Visibility(time.Now()))
+	termPid := uint32(term.Pid)
+	daemon := srv.scratchpads["daemon"]
+	daemonPid := daemon.Pid
+	srv.mu.Unlock()
+	t.Cleanup(func() { _ = syscall.Kill(-daemonPid, syscall.SIGKILL) })
+
+	_, ok := srv.scratchpads["lazy"]
+	r.False(ok)
+
+	// window of the spawned process goes straight to the scratchpad
+	client.commands = nil
+	r.NoError(srv.OnWindow(ctx, newWindowEvent(31, termPid, "")))
+	r.Equal([]string{
+		"[con_id=31] mark --add _scratch_term",
+		`[con_mark="^_scratch_term$"] move scratchpad`,
+	}, client.commands)
+
+	// window of the daemon never appeared, but its process is still running
+	srv.mu.Lock()
+	daemon.pendingUntil = time.Time{}
+	r.Equal(VisibilityNone, daemon.Visibility(time.Now()))
+	r.True(daemon.IsRunning(time.Now()))
+	srv.mu.Unlock()
+
+	// running scratchpads are not started again
+	client.commands = nil
+	r.NoError(srv.Prestart(ctx))
+	r.Empty(client.commands)
+	srv.mu.Lock()
+	r.Equal(daemonPid, daemon.Pid)
+	srv.mu.Unlock()
+}
+
+func TestServer_AdoptRelease(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	pid := uint32(7777)
+	window := &sway.Node{ID: 40, Type: sway.NodeCon, PID: &pid, Focused: true}
+
+	client := &fakeClient{
+		tree:       newTree(window, "1"),
+		workspaces: []sway.Workspace{{Name: "1", Output: "eDP-1", Focused: true}},
+	}
+	srv := NewServer(log.New(io.Discard, "", 0), client, nil, core.NewNodeNinja(client))
+	srv.SetLogDir(t.TempDir())
+
+	placement := func() *Definition {
+		return &Definition{Position: PositionRight, WindowWidth: Length{Value: 1}, WindowHeight: Length{Value: 1}}
+	}
+	const target = `[con_mark="^_scratch_music$"]`
+
+	// focused window becomes a scratchpad
+	r.NoError(srv.Run(ctx, CommandAdopt, "music", placement()))
+	r.Equal([]string{
+		"[con_id=40] mark --add _scratch_music",
+		target + " move scratchpad; " + target + " scratchpad show",
+	}, client.commands)
+	r.Equal(VisibilityVisible, srv.scratchpads["music"].Visibility(time.Now()))
+
+	// output goes to a log file that is closed with the scratchpad
+	output, ok := srv.scratchpads["music"].output.(*logFile)
+	r.True(ok)
+	_, err := output.Write([]byte("playing\n"))
+	r.NoError(err)
+	r.NotNil(output.file)
+
+	// the same window can't be adopted twice
+	r.Error(srv.Run(ctx, CommandAdopt, "music", placement()))
+	r.Error(srv.Run(ctx, CommandAdopt, "other", placement()))
+
+	// toggles like any other scratchpad
+	client.commands = nil
+	r.NoError(srv.Run(ctx, CommandToggle, "music", nil))
+	r.Equal([]string{target + " move scratchpad"}, client.commands)
+
+	// hidden window is shown and returned to tiling
+	client.commands = nil
+	r.NoError(srv.Run(ctx, CommandRelease, "music", nil))
+	r.Equal([]string{
+		target + " scratchpad show; " + target + " floating disable; " + target + " unmark _scratch_music",
+	}, client.commands)
+	_, ok = srv.scratchpads["music"]
+	r.False(ok)
+	r.Nil(output.file)
+
+	r.Error(srv.Run(ctx, CommandRelease, "music", nil))
+
+	// adopted window that was closed can't be started again
+	r.NoError(srv.Run(ctx, CommandAdopt, "music", placement()))
+	r.NoError(srv.OnWindow(ctx, sway.WindowEvent{Change: sway.WindowClose, Container: *window}))
+	r.ErrorContains(srv.Run(ctx, CommandShow, "music", nil), "no command")
+
+	// adopting again replaces the closed scratchpad, the log file is shared
+	_, err = output.Write([]byte("stopped\n"))
+	r.NoError(err)
+	r.NoError(srv.Run(ctx, CommandAdopt, "music", placement()))
+	r.Same(output, srv.scratchpads["music"].output)
+	r.Nil(output.file)
+
+	// focused node has to be a window
+	window.Type = sway.NodeWorkspace
+	r.Error(srv.Run(ctx, CommandAdopt, "ws", placement()))
+}
+
+func TestScratchpad_ClaimExisting(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	appID := "kitty"
+	pid := uint32(7777)
+	// the user's own terminal and a terminal of another scratchpad
+	own := &sway.Node{ID: 50, Type: sway.NodeCon, PID: &pid, AppID: &appID}
+	other := &sway.Node{ID: 51, Type: sway.NodeCon, PID: &pid, AppID: &appID, Marks: []string{scratchpadMark("other")}}
+
+	client := &fakeClient{}
+	newTerm := func(claimExisting bool) *Scratchpad {
+		return newScratchpad(log.New(io.Discard, "", 0), client, "term", &Definition{
+			Cmd: "true", WindowWidth: Length{Value: 1}, WindowHeight: Length{Value: 1},
+			Criteria: &Criteria{AppID: "kitty"}, ClaimExisting: claimExisting,
+		})
+	}
+
+	// windows are not taken over unless asked to
+	client.tree = newTree(own, "1")
+	sc := newTerm(false)
+	r.NoError(sc.start(ctx, nil))
+	r.Empty(client.commands)
+	r.NotZero(sc.Pid)
+
+	// windows of other scratchpads are never taken over
+	client.tree = newTree(other, "1")
+	_, err := newTerm(true).findCriteriaWindow(ctx)
+	r.ErrorIs(err, errNoMatchingNode)
+
+	client.tree = newTree(own, "1")
+	node, err := newTerm(true).findCriteriaWindow(ctx)
+	r.NoError(err)
+	r.Equal(own.ID, node.ID)
+}
+
+func TestServer_UndefinedName(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	client := &fakeClient{
+		workspaces: []sway.Workspace{{Name: "1", Output: "eDP-1", Focused: true}},
+	}
+	srv := NewServer(log.New(io.Discard, "", 0), client, nil, core.NewNodeNinja(client))
+	srv.SetDefinitions(map[string]*Definition{
+		"term": {Cmd: "true", Position: PositionDropdown, WindowWidth: Length{Value: 1}, WindowHeight: Length{Value: 1}},
+	})
+
+	// defined name uses its definition
+	r.NoError(srv.Run(ctx, CommandToggle, "term", nil))
+	r.Equal(PositionDropdown, srv.scratchpads["term"].def.Position)
+
+	// name that is not defined is run as a command with the default placement
+	r.NoError(srv.Run(ctx, CommandToggle, "sleep 1", nil))
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	sc := srv.scratchpads["sleep 1"]
+	r.Equal("sleep 1", sc.def.Cmd)
+	r.Equal(PositionCenter, sc.def.Position)
+	r.Equal(defaultWindowWidth, sc.def.WindowWidth)
+	r.Equal(defaultWindowHeight, sc.def.WindowHeight)
+	r.NotZero(sc.Pid)
+}
+
+func TestServer_CallerNotInherited(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	out := filepath.Join(t.TempDir(), "out")
+	t.Setenv("CALLER_ONLY", "")
+
+	client := &fakeClient{
+		workspaces: []sway.Workspace{{Name: "1", Output: "eDP-1", Focused: true}},
+	}
+	srv := NewServer(log.New(io.Discard, "", 0), client, nil, core.NewNodeNinja(client))
+	srv.SetDefinitions(map[string]*Definition{
+		"term": {Cmd: `echo "$(pwd -P):$CALLER_ONLY" > ` + out, WindowWidth: Length{Value: 1}, WindowHeight: Length{Value: 1}},
+	})
+
+	caller := &Caller{
+		Dir: t.TempDir(),
+		Env: append(os.Environ(), "CALLER_ONLY=1"),
+	}
+	r.NoError(srv.RunFrom(ctx, caller, CommandToggle, "term", nil))
+
+	srv.mu.Lock()
+	r.Nil(srv.scratchpads["term"].caller)
+	srv.mu.Unlock()
+
+	// process runs in the server's directory and environment
+	wd, err := os.Getwd()
+	r.NoError(err)
+	wd, err = filepath.EvalSymlinks(wd)
+	r.NoError(err)
+	r.Eventually(func() bool {
+		contents, err := os.ReadFile(out)
+		return err == nil && string(contents) == wd+":\n"
+	}, 5*time.Second, 10*time.Millisecond)
+}
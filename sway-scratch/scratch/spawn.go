@@ -0,0 +1,119 @@
+package scratch
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Caller describes the environment of the client that invoked a command.
+type Caller struct {
+	// Dir is the working directory of the caller.
+	Dir string
+	// Env is the environment of the caller in "KEY=value" form.
+	Env []string
+}
+
+// Command returns the command of the definition for display purposes.
+func (d *Definition) Command() string {
+	if len(d.Argv) > 0 {
+		return strings.Join(d.Argv, " ")
+	}
+	return d.Cmd
+}
+
+// hasCommand reports whether the definition has something to start.
+func (d *Definition) hasCommand() bool {
+	return d.Cmd != "" || len(d.Argv) > 0
+}
+
+// validateCommand validates the command, its environment and working directory.
+func (d *Definition) validateCommand() error {
+	if d.Cmd != "" && len(d.Argv) > 0 {
+		return errors.New("cmd and argv are mutually exclusive")
+	}
+	if !d.hasCommand() {
+		return errors.New("no command provided")
+	}
+	if len(d.Argv) > 0 && d.Argv[0] == "" {
+		return errors.New("empty program in argv")
+	}
+	for key := range d.Env {
+		if key == "" || strings.ContainsAny(key, "=\x00") {
+			return fmt.Errorf("invalid environment variable name: %q", key)
+		}
+	}
+	return nil
+}
+
+// expandHome replaces the leading "~" of the path with the home directory.
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("os.UserHomeDir: %w", err)
+	}
+
+	return filepath.Join(home, path[1:]), nil
+}
+
+// command prepares the process of the scratchpad.
+// Argv is executed directly, Cmd is passed to the shell.
+func (s *Scratchpad) command() (*exec.Cmd, error) {
+	var cmd *exec.Cmd
+	if len(s.def.Argv) > 0 {
+		cmd = exec.Command(s.def.Argv[0], s.def.Argv[1:]...)
+	} else {
+		cmd = exec.Command("sh", "-c", s.def.Cmd)
+	}
+
+	// caller's environment replaces the server's one
+	caller := s.caller
+	if !s.def.InheritCaller {
+		caller = nil
+	}
+
+	env := os.Environ()
+	if caller != nil && caller.Env != nil {
+		env = caller.Env
+	}
+	cmd.Env = withEnv(env, s.def.Env)
+
+	if caller != nil {
+		cmd.Dir = caller.Dir
+	}
+	if s.def.Dir != "" {
+		dir, err := expandHome(s.def.Dir)
+		if err != nil {
+			return nil, err
+		}
+		cmd.Dir = dir
+	}
+
+	return cmd, nil
+}
+
+// withEnv returns the environment with extra variables appended.
+// Later entries take precedence, so the extras override existing values.
+func withEnv(env []string, extra map[string]string) []string {
+	keys := make([]string, 0, len(extra))
+	for key := range extra {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	out := make([]string, 0, len(env)+len(keys))
+	out = append(out, env...)
+	for _, key := range keys {
+		out = append(out, key+"="+extra[key])
+	}
+
+	return out
+}
@@ -0,0 +1,131 @@
+package scratch
+
+import (
+	"io"
+	"log"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefinition_ValidateCommand(t *testing.T) {
+	testCases := []struct {
+		comment string
+		def     Definition
+		valid   bool
+	}{
+		{comment: "shell command", def: Definition{Cmd: "kitty"}, valid: true},
+		{comment: "argv", def: Definition{Argv: []string{"foot", "-e", "nvim"}}, valid: true},
+		{comment: "no command", def: Definition{}, valid: false},
+		{comment: "both", def: Definition{Cmd: "kitty", Argv: []string{"foot"}}, valid: false},
+		{comment: "empty program", def: Definition{Argv: []string{"", "-e"}}, valid: false},
+		{comment: "env", def: Definition{Cmd: "kitty", Env: map[string]string{"EDITOR": "nvim"}}, valid: true},
+		{comment: "empty env name", def: Definition{Cmd: "kitty", Env: map[string]string{"": "x"}}, valid: false},
+		{comment: "env name with =", def: Definition{Cmd: "kitty", Env: map[string]string{"A=B": "x"}}, valid: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.comment, func(t *testing.T) {
+			err := tc.def.validateCommand()
+			if tc.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestScratchpad_Command(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("SCRATCH_TEST", "server")
+
+	caller := &Caller{
+		Dir: "/caller/project",
+		Env: []string{"SCRATCH_TEST=caller", "CALLER_ONLY=1"},
+	}
+
+	testCases := []struct {
+		comment string
+		def     Definition
+		caller  *Caller
+
+		expectedArgs []string
+		expectedDir  string
+		// expected values of environment variables ("" if unset)
+		expectedEnv map[string]string
+	}{
+		{
+			comment:      "shell command in server environment",
+			def:          Definition{Cmd: "kitty --hold"},
+			caller:       caller,
+			expectedArgs: []string{"sh", "-c", "kitty --hold"},
+			expectedEnv:  map[string]string{"SCRATCH_TEST": "server", "CALLER_ONLY": ""},
+		},
+		{
+			comment:      "argv skips the shell",
+			def:          Definition{Argv: []string{"foot", "-e", "nvim"}},
+			expectedArgs: []string{"foot", "-e", "nvim"},
+		},
+		{
+			comment:      "extra env and expanded dir",
+			def:          Definition{Cmd: "kitty", Dir: "~/notes", Env: map[string]string{"SCRATCH_TEST": "extra"}},
+			expectedArgs: []string{"sh", "-c", "kitty"},
+			expectedDir:  filepath.Join(home, "notes"),
+			expectedEnv:  map[string]string{"SCRATCH_TEST": "extra"},
+		},
+		{
+			comment:      "caller context",
+			def:          Definition{Cmd: "kitty", InheritCaller: true},
+			caller:       caller,
+			expectedArgs: []string{"sh", "-c", "kitty"},
+			expectedDir:  "/caller/project",
+			expectedEnv:  map[string]string{"SCRATCH_TEST": "caller", "CALLER_ONLY": "1"},
+		},
+		{
+			comment:      "definition overrides caller context",
+			def:          Definition{Cmd: "kitty", InheritCaller: true, Dir: "/tmp", Env: map[string]string{"SCRATCH_TEST": "extra"}},
+			caller:       caller,
+			expectedArgs: []string{"sh", "-c", "kitty"},
+			expectedDir:  "/tmp",
+			expectedEnv:  map[string]string{"SCRATCH_TEST": "extra", "CALLER_ONLY": "1"},
+		},
+		{
+			comment:      "caller context is not known",
+			def:          Definition{Cmd: "kitty", InheritCaller: true},
+			expectedArgs: []string{"sh", "-c", "kitty"},
+			expectedEnv:  map[string]string{"SCRATCH_TEST": "server"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.comment, func(t *testing.T) {
+			r := require.New(t)
+
+			sc := newScratchpad(log.New(io.Discard, "", 0), &fakeClient{}, "term", &tc.def)
+			sc.caller = tc.caller
+
+			cmd, err := sc.command()
+			r.NoError(err)
+			r.Equal(tc.expectedArgs, cmd.Args)
+			r.Equal(tc.expectedDir, cmd.Dir)
+
+			for key, value := range tc.expectedEnv {
+				r.Equal(value, lookupEnv(cmd.Environ(), key), key)
+			}
+		})
+	}
+}
+
+// lookupEnv returns the effective value of the variable (the last one wins).
+func lookupEnv(env []string, key string) string {
+	value := ""
+	for _, kv := range env {
+		if len(kv) > len(key) && kv[:len(key)] == key && kv[len(key)] == '=' {
+			value = kv[len(key)+1:]
+		}
+	}
+	return value
+}
@@ -0,0 +1,307 @@
+package scratch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// RespawnPolicy decides if the scratchpad process is started again after it exits.
+type RespawnPolicy int
+
+const (
+	RespawnNever RespawnPolicy = iota
+	RespawnOnFailure
+	RespawnAlways
+)
+
+func (p RespawnPolicy) String() string {
+	switch p {
+	case RespawnNever:
+		return "never"
+	case RespawnOnFailure:
+		return "on-failure"
+	case RespawnAlways:
+		return "always"
+	}
+	return "unknown"
+}
+
+func parseRespawnPolicy(in string) (RespawnPolicy, error) {
+	input := strings.ToLower(in)
+
+	for p := RespawnNever; p <= RespawnAlways; p++ {
+		if input == p.String() {
+			return p, nil
+		}
+	}
+
+	return 0, fmt.Errorf("invalid respawn policy: %q - should be never, on-failure or always", in)
+}
+
+func (p *RespawnPolicy) UnmarshalText(text []byte) error {
+	policy, err := parseRespawnPolicy(string(text))
+	if err != nil {
+		return err
+	}
+	*p = policy
+	return nil
+}
+
+// shouldRespawn reports if the process that exited with the provided error is started again.
+func (p RespawnPolicy) shouldRespawn(exitErr error) bool {
+	switch p {
+	case RespawnAlways:
+		return true
+	case RespawnOnFailure:
+		return exitErr != nil
+	}
+	return false
+}
+
+const (
+	// delay before the first respawn, doubled on each consecutive one
+	minRespawnDelay = time.Second
+	maxRespawnDelay = time.Minute
+	// process that ran at least this long resets the respawn backoff
+	stableRuntime = 10 * time.Second
+)
+
+// respawnDelay returns the delay before the respawn after the provided number of consecutive ones.
+func respawnDelay(restarts int) time.Duration {
+	delay := minRespawnDelay
+	for range restarts {
+		delay *= 2
+		if delay >= maxRespawnDelay {
+			return maxRespawnDelay
+		}
+	}
+	return delay
+}
+
+// exitStatus describes how the process exited.
+func exitStatus(err error) string {
+	if err == nil {
+		return "exit status 0"
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ProcessState.String()
+	}
+	return err.Error()
+}
+
+// handleExit gets called when the spawned process of the scratchpad exits.
+func (s *Server) handleExit(sc *Scratchpad, pid int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.log.Printf("scratchpad %q: process %d exited: %s", sc.id, pid, exitStatus(err))
+
+	// a newer process was started in the meantime
+	if sc.Pid != pid {
+		return
+	}
+
+	sc.Pid = 0
+	sc.exitErr = err
+	if err != nil {
+		// failed process won't open its window
+		sc.pendingUntil = time.Time{}
+	}
+
+	s.checkStopped(sc)
+}
+
+// checkStopped emits an exit event and schedules a respawn once both
+// the process and the window of the scratchpad are gone.
+// Window might outlive the process if it was handed over to another one.
+func (s *Server) checkStopped(sc *Scratchpad) {
+	if sc.Pid != 0 || sc.IsTracked() || sc.IsPending(time.Now()) {
+		return
+	}
+
+	e := sc.newEvent(EventExited)
+	if !sc.startedAt.IsZero() {
+		e.Status = exitStatus(sc.exitErr)
+	}
+	s.events.publish(e)
+
+	// killed on purpose
+	if sc.stopping {
+		sc.stopping = false
+		sc.restarts = 0
+
+		if sc.restart {
+			sc.restart = false
+			err := sc.spawn(sc.restartHidden)
+			if err != nil {
+				s.log.Printf("scratchpad %q: sc.spawn: %s", sc.id, err)
+			}
+		}
+		return
+	}
+
+	if !sc.def.Respawn.shouldRespawn(sc.exitErr) {
+		sc.restarts = 0
+		return
+	}
+
+	if time.Since(sc.startedAt) >= stableRuntime {
+		sc.restarts = 0
+	}
+	delay := respawnDelay(sc.restarts)
+	sc.restarts++
+
+	s.log.Printf("scratchpad %q: respawning in %s", sc.id, delay)
+	time.AfterFunc(delay, func() {
+		s.respawn(sc)
+	})
+}
+
+// respawn starts the process of a stopped scratchpad, its window stays hidden.
+func (s *Server) respawn(sc *Scratchpad) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// scratchpad was replaced or started in the meantime
+	if s.scratchpads[sc.id] != sc || sc.Pid != 0 || sc.IsTracked() || sc.IsPending(time.Now()) {
+		return
+	}
+
+	err := sc.spawn(true)
+	if err != nil {
+		s.log.Printf("scratchpad %q: sc.spawn: %s", sc.id, err)
+	}
+}
+
+// killGracePeriod is how long the process group has to exit after SIGTERM before it's killed.
+const killGracePeriod = 3 * time.Second
+
+// groupPollInterval is how often a terminated process group is checked for members.
+const groupPollInterval = 100 * time.Millisecond
+
+// groupAlive reports if the process group of the last spawned process still has members.
+// Children of a shell or a forking launcher stay in the group after the spawned process exits.
+func (s *Scratchpad) groupAlive() bool {
+	if s.pgid == 0 {
+		return false
+	}
+	return processGroupAlive(s.pgid)
+}
+
+func processGroupAlive(pgid int) bool {
+	err := syscall.Kill(-pgid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// terminate sends SIGTERM to the process group of the spawned process
+// and SIGKILL if the group doesn't exit within the grace period.
+func (s *Scratchpad) terminate() {
+	pgid := s.pgid
+
+	err := syscall.Kill(-pgid, syscall.SIGTERM)
+	if err != nil && !errors.Is(err, syscall.ESRCH) {
+		s.log.Printf("scratchpad %q: syscall.Kill: %s", s.id, err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(groupPollInterval)
+		defer ticker.Stop()
+		deadline := time.After(killGracePeriod)
+
+		for processGroupAlive(pgid) {
+			select {
+			case <-ticker.C:
+				continue
+			case <-deadline:
+			}
+
+			s.log.Printf("scratchpad %q: process group %d didn't exit in %s, killing it", s.id, pgid, killGracePeriod)
+			err := syscall.Kill(-pgid, syscall.SIGKILL)
+			if err != nil && !errors.Is(err, syscall.ESRCH) {
+				s.log.Printf("scratchpad %q: syscall.Kill: %s", s.id, err)
+			}
+			return
+		}
+	}()
+}
+
+// IsRunning reports if the scratchpad has a process or a window.
+func (s *Scratchpad) IsRunning(now time.Time) bool {
+	return s.Pid != 0 || s.Visibility(now) != VisibilityNone
+}
+
+// Kill closes the window and terminates the process group of the spawned process.
+// Killed scratchpads are not respawned.
+func (s *Scratchpad) Kill(ctx context.Context) error {
+	if !s.IsRunning(time.Now()) {
+		// children of the exited process might still be around
+		if s.groupAlive() {
+			s.terminate()
+		}
+		return nil
+	}
+
+	s.stopping = true
+	// window won't appear anymore
+	s.pendingUntil = time.Time{}
+
+	if s.IsTracked() {
+		err := s.runCommand(ctx, "[%s] kill")
+		if err != nil && !errors.Is(err, errNoMatchingNode) {
+			return err
+		}
+	}
+
+	if s.groupAlive() {
+		s.terminate()
+	}
+
+	// window was already gone and there is no process to wait for
+	if !s.IsRunning(time.Now()) {
+		s.stopping = false
+	}
+
+	return nil
+}
+
+// Restart kills the scratchpad and starts it again with the same visibility.
+// Scratchpad that is not running is started hidden.
+func (s *Scratchpad) Restart(ctx context.Context) error {
+	if !s.def.hasCommand() {
+		return errors.New("adopted window has no command to start it again")
+	}
+
+	now := time.Now()
+	if !s.IsRunning(now) {
+		// leftovers of the exited process are terminated
+		err := s.Kill(ctx)
+		if err != nil {
+			return err
+		}
+		return s.start(ctx, nil)
+	}
+
+	s.restart = true
+	s.restartHidden = s.Visibility(now) != VisibilityVisible
+
+	err := s.Kill(ctx)
+	if err != nil {
+		s.restart = false
+		return err
+	}
+
+	// nothing to wait for
+	if !s.IsRunning(time.Now()) {
+		s.restart = false
+		return s.spawn(s.restartHidden)
+	}
+
+	return nil
+}
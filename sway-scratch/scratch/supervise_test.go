@@ -0,0 +1,238 @@
+package scratch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kndndrj/sway-scripts/internal/core"
+)
+
+func TestRespawnPolicy(t *testing.T) {
+	r := require.New(t)
+
+	failure := errors.New("exit status 1")
+
+	r.False(RespawnNever.shouldRespawn(nil))
+	r.False(RespawnNever.shouldRespawn(failure))
+	r.False(RespawnOnFailure.shouldRespawn(nil))
+	r.True(RespawnOnFailure.shouldRespawn(failure))
+	r.True(RespawnAlways.shouldRespawn(nil))
+	r.True(RespawnAlways.shouldRespawn(failure))
+
+	for p := RespawnNever; p <= RespawnAlways; p++ {
+		parsed, err := parseRespawnPolicy(p.String())
+		r.NoError(err)
+		r.Equal(p, parsed)
+	}
+	_, err := parseRespawnPolicy("sometimes")
+	r.Error(err)
+}
+
+func TestRespawnDelay(t *testing.T) {
+	r := require.New(t)
+
+	r.Equal(time.Second, respawnDelay(0))
+	r.Equal(2*time.Second, respawnDelay(1))
+	r.Equal(32*time.Second, respawnDelay(5))
+	r.Equal(time.Minute, respawnDelay(6))
+	r.Equal(time.Minute, respawnDelay(1000))
+}
+
+func TestServer_ProcessExit(t *testing.T) {
+	r := require.New(t)
+
+	srv := NewServer(log.New(io.Discard, "", 0), &fakeClient{}, nil, nil)
+	srv.SetDefinitions(map[string]*Definition{
+		"once":  {Cmd: "exit 3", WindowWidth: Length{Value: 1}, WindowHeight: Length{Value: 1}},
+		"again": {Cmd: "exit 3", WindowWidth: Length{Value: 1}, WindowHeight: Length{Value: 1}, Respawn: RespawnOnFailure},
+	})
+
+	events, unsubscribe := srv.Subscribe()
+	defer unsubscribe()
+
+	next := func() *Event {
+		select {
+		case e := <-events:
+			return e
+		case <-time.After(5 * time.Second):
+			r.FailNow("no event received")
+		}
+		return nil
+	}
+
+	spawn := func(id string) *Scratchpad {
+		srv.mu.Lock()
+		defer srv.mu.Unlock()
+
+		sc, err := srv.getScratchpad(id, nil)
+		r.NoError(err)
+		r.NoError(sc.spawn(false))
+		return sc
+	}
+
+	// process is reaped and the state is updated
+	once := spawn("once")
+	r.Equal(EventSpawned, next().Type)
+
+	e := next()
+	r.Equal(EventExited, e.Type)
+	r.Equal("once", e.ID)
+	r.Equal("exit status 3", e.Status)
+
+	srv.mu.Lock()
+	r.Zero(once.Pid)
+	r.Equal(VisibilityNone, once.Visibility(time.Now()))
+	srv.mu.Unlock()
+
+	// failed process is started again
+	again := spawn("again")
+	r.Equal(EventSpawned, next().Type)
+	r.Equal(EventExited, next().Type)
+
+	e = next()
+	r.Equal(EventSpawned, e.Type)
+	r.Equal("again", e.ID)
+
+	// stop respawning
+	srv.mu.Lock()
+	again.def.Respawn = RespawnNever
+	srv.mu.Unlock()
+	r.Equal(EventExited, next().Type)
+}
+
+func TestServer_KillRestart(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	srv := NewServer(log.New(io.Discard, "", 0), &fakeClient{}, nil, nil)
+	srv.SetDefinitions(map[string]*Definition{
+		// children of the shell are in the same process group
+		"term": {Cmd: "sleep 30; true", WindowWidth: Length{Value: 1}, WindowHeight: Length{Value: 1}, Respawn: RespawnAlways},
+	})
+
+	events, unsubscribe := srv.Subscribe()
+	defer unsubscribe()
+
+	next := func() *Event {
+		select {
+		case e := <-events:
+			return e
+		case <-time.After(5 * time.Second):
+			r.FailNow("no event received")
+		}
+		return nil
+	}
+
+	// scratchpad that was never started is not created by other commands
+	for _, cmd := range []Command{CommandRestart, CommandKill, CommandHide, CommandResetShape} {
+		r.ErrorContains(srv.Run(ctx, cmd, "term", nil), "not running", cmd)
+	}
+	r.Empty(srv.scratchpads)
+
+	// restart of a stopped scratchpad starts it
+	_, err := srv.getScratchpad("term", nil)
+	r.NoError(err)
+	r.NoError(srv.Run(ctx, CommandRestart, "term", nil))
+	first := next()
+	r.Equal(EventSpawned, first.Type)
+
+	// restart stops the process group and starts it again
+	r.NoError(srv.Run(ctx, CommandRestart, "term", nil))
+	e := next()
+	r.Equal(EventExited, e.Type)
+	r.Equal("signal: terminated", e.Status)
+	e = next()
+	r.Equal(EventSpawned, e.Type)
+	r.NotEqual(first.Pid, e.Pid)
+
+	// killed scratchpad is not respawned
+	r.NoError(srv.Run(ctx, CommandKill, "term", nil))
+	r.Equal(EventExited, next().Type)
+
+	select {
+	case e := <-events:
+		r.Failf("unexpected event", "%+v", e)
+	case <-time.After(minRespawnDelay + 500*time.Millisecond):
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	r.False(srv.scratchpads["term"].IsRunning(time.Now()))
+}
+
+func TestServer_KillLeftoverGroup(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	pidFile := filepath.Join(t.TempDir(), "child")
+	srv := NewServer(log.New(io.Discard, "", 0), &fakeClient{}, nil, nil)
+	srv.SetDefinitions(map[string]*Definition{
+		// launcher exits right away, its child keeps running in the process group
+		"term": {Cmd: "sleep 30 & echo $! > " + pidFile, WindowWidth: Length{Value: 1}, WindowHeight: Length{Value: 1}},
+	})
+
+	events, unsubscribe := srv.Subscribe()
+	defer unsubscribe()
+
+	srv.mu.Lock()
+	sc, err := srv.getScratchpad("term", nil)
+	r.NoError(err)
+	r.NoError(sc.spawn(true))
+	srv.mu.Unlock()
+
+	select {
+	case e := <-events:
+		r.Equal(EventSpawned, e.Type)
+	case <-time.After(5 * time.Second):
+		r.FailNow("no event received")
+	}
+
+	// launcher is gone
+	r.Eventually(func() bool {
+		srv.mu.Lock()
+		defer srv.mu.Unlock()
+		return sc.Pid == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	data, err := os.ReadFile(pidFile)
+	r.NoError(err)
+	child, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	r.NoError(err)
+	t.Cleanup(func() { _ = syscall.Kill(child, syscall.SIGKILL) })
+
+	// the child is terminated with the group
+	r.NoError(srv.Run(ctx, CommandKill, "term", nil))
+	r.Eventually(func() bool {
+		stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", child))
+		// orphan might stay a zombie until it's reaped
+		return err != nil || strings.Contains(string(stat), ") Z ")
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestScratchpad_CloseOnHide(t *testing.T) {
+	r := require.New(t)
+
+	client := &fakeClient{}
+	sc, err := NewScratchpad(log.New(io.Discard, "", 0), client, "calc", &Definition{
+		Cmd: "qalculate", WindowWidth: Length{Value: 1}, WindowHeight: Length{Value: 1}, CloseOnHide: true,
+	})
+	r.NoError(err)
+	sc.ConID = 10
+	sc.SetLocation(&core.NodeLocation{Workspace: "1", Output: "eDP-1"})
+
+	r.NoError(sc.Hide(context.Background()))
+	r.Equal([]string{`[con_mark="^_scratch_calc$"] kill`}, client.commands)
+	r.True(sc.stopping)
+}